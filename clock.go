@@ -0,0 +1,36 @@
+package gofherd
+
+import "time"
+
+// Clock interface is accepted by SetClock and is the source of time for progress,
+// utilization, Work history, events, the rate limit and the retry backoff.
+// It can be replaced with a fake clock in tests, see the gofherdtest package.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct {
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// SetClock replaces the clock used by gofherd. It must be called before Start.
+func (gf *Gofherd) SetClock(c Clock) {
+	gf.clock = c
+	gf.progress.now = c.Now
+	gf.progress.start = c.Now()
+	gf.gophers.now = c.Now
+	gf.registry.now = c.Now
+	gf.failures.now = c.Now
+	gf.events.now = c.Now
+	gf.limiter.clock = c
+	gf.scheduler.clock = c
+	gf.recurring.clock = c
+}
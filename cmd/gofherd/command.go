@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	gf "github.com/darshanime/gofherd"
+)
+
+func parseExitCodes(codes string) ([]int, error) {
+	parsed := []int{}
+	for _, code := range strings.Split(codes, ",") {
+		code = strings.TrimSpace(code)
+		if code == "" {
+			continue
+		}
+		num, err := strconv.Atoi(code)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, num)
+	}
+	return parsed, nil
+}
+
+// placeholders are replaced with templates for gofherd.ExecOptions. Literal braces
+// are escaped, so that they reach the command as they are.
+var placeholders = strings.NewReplacer(
+	"{}", "{{.Body}}",
+	"{#}", "{{.ID}}",
+	"{{", `{{"{{"}}`,
+	"}}", `{{"}}"}}`,
+)
+
+// execArgs returns the templates of the command, replacing `{}` with the line and `{#}`
+// with the Work ID. Without `{}`, the line is appended as the last argument.
+func execArgs(command []string) []string {
+	args := make([]string, 0, len(command)+1)
+	replaced := false
+	for _, arg := range command {
+		if strings.Contains(arg, "{}") {
+			replaced = true
+		}
+		args = append(args, placeholders.Replace(arg))
+	}
+	if !replaced {
+		args = append(args, "{{.Body}}")
+	}
+	return args
+}
+
+func newProcessor(command []string, retryCodes []int) (*gf.ExecProcessor, error) {
+	return gf.NewExecProcessor(gf.ExecOptions{Args: execArgs(command), RetryCodes: retryCodes})
+}
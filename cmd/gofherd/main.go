@@ -0,0 +1,128 @@
+// Command gofherd runs a command per line of input with bounded parallelism, like
+// `xargs -P`, using a gofherd herd. The parallelism can be changed while it runs with
+// `PATCH /herd` on the control server, which also serves `/metrics`.
+//
+// Usage:
+//
+//	gofherd [flags] command [args...]
+//
+// `{}` in the arguments is replaced with the line, and `{#}` with its Work ID, the number of
+// the line counted from 0, skipping blank lines. Without `{}`, the line is appended as the last argument. Exit code 0 is Success,
+// the codes in -retry-codes are Retry, and any other exit code is Failure. A command
+// exceeding -timeout is killed and retried. The result of every line is written to stdout
+// as a JSON line, and gofherd exits with 1 if any line failed.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	gf "github.com/darshanime/gofherd"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("gofherd", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: gofherd [flags] command [args...]\n\n")
+		flags.PrintDefaults()
+	}
+	parallelism := flags.Int64("P", int64(runtime.NumCPU()), "number of commands run in parallel, the herd size")
+	inputFile := flags.String("a", "", "read lines from `file` instead of stdin")
+	retries := flags.Int64("retries", 0, "maximum number of times a line is retried")
+	backoff := flags.Duration("retry-backoff", 0, "delay before a line is retried")
+	timeout := flags.Duration("timeout", 0, "maximum runtime of each command, 0 is no limit")
+	retryCodes := flags.String("retry-codes", "75", "comma separated exit `codes` which are retried")
+	addr := flags.String("addr", "127.0.0.1:2112", "address of the control server, serving /herd and /metrics")
+	noServer := flags.Bool("no-server", false, "do not start the control server")
+	authToken := flags.String("auth-token", "", "bearer `token` required by the control server")
+	verbose := flags.Bool("v", false, "log herd events to stderr")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	codes, err := parseExitCodes(*retryCodes)
+	if err != nil {
+		fmt.Fprintf(stderr, "gofherd: invalid -retry-codes: %s\n", err)
+		return 2
+	}
+	input := stdin
+	if *inputFile != "" {
+		file, err := os.Open(*inputFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "gofherd: %s\n", err)
+			return 2
+		}
+		defer file.Close()
+		input = file
+	}
+
+	processor, err := newProcessor(flags.Args(), codes)
+	if err != nil {
+		fmt.Fprintf(stderr, "gofherd: %s\n", err)
+		return 2
+	}
+	defer processor.Close()
+	herd := gf.NewWithContext(processor.Process)
+	herd.SetHerdSize(*parallelism)
+	if err := herd.SetConfig(gf.Config{MaxRetries: *retries, RetryBackoff: *backoff, WorkTimeout: *timeout}); err != nil {
+		fmt.Fprintf(stderr, "gofherd: %s\n", err)
+		return 2
+	}
+	herd.SetLogger(log.New(stderr, "gofherd: ", log.LstdFlags))
+	if !*verbose {
+		herd.SetLogLevel(gf.LevelWarn)
+	}
+	herd.SetAddr(*addr)
+	herd.SetAuthToken(*authToken)
+	if *noServer {
+		herd.DisableServer()
+	}
+	failed := int64(0)
+	herd.AddFailureCallback(func(w *gf.Work) { atomic.AddInt64(&failed, 1) })
+
+	if err := herd.Start(); err != nil {
+		fmt.Fprintf(stderr, "gofherd: %s\n", err)
+		return 2
+	}
+	start := time.Now()
+	go func() {
+		if _, err := herd.Consume(ctx, gf.NewLinesSource(input)); err != nil {
+			fmt.Fprintf(stderr, "gofherd: could not read input: %s\n", err)
+			herd.CloseInputChan()
+		}
+	}()
+	// kill the running commands on interrupt
+	stop := context.AfterFunc(ctx, processor.Close)
+	defer stop()
+	if err := herd.Drain(ctx, gf.NewJSONLinesWriterSink(stdout)); err != nil {
+		fmt.Fprintf(stderr, "gofherd: %s\n", err)
+		return 1
+	}
+	progress := herd.Progress()
+	if *verbose {
+		fmt.Fprintf(stderr, "gofherd: %d succeeded, %d failed, %d retries in %s\n", progress.Success, progress.Failure, progress.Retries, time.Since(start).Round(time.Millisecond))
+	}
+	if atomic.LoadInt64(&failed) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	gf "github.com/darshanime/gofherd"
+)
+
+type outputLine struct {
+	ID      string        `json:"id"`
+	Status  string        `json:"status"`
+	Retries int64         `json:"retries"`
+	Result  gf.ExecResult `json:"result"`
+}
+
+func runCLI(t *testing.T, input string, args ...string) (int, []outputLine, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), append([]string{"-no-server"}, args...), strings.NewReader(input), &stdout, &stderr)
+	lines := []outputLine{}
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var out outputLine
+		if err := json.Unmarshal([]byte(line), &out); err != nil {
+			t.Fatalf("could not parse output line %q: %s", line, err)
+		}
+		lines = append(lines, out)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].ID < lines[j].ID })
+	return code, lines, stderr.String()
+}
+
+func TestRunTemplatesCommand(t *testing.T) {
+	code, lines, stderr := runCLI(t, "a\n\nb\n", "-P", "2", "echo", "{#}:{}")
+	if code != 0 || len(lines) != 2 {
+		t.Fatalf("expected 2 successful lines, got code: %d, lines: %+v, stderr: %s", code, lines, stderr)
+	}
+	if lines[0].Result.Stdout != "0:a\n" || lines[1].Result.Stdout != "1:b\n" || lines[1].Status != "success" {
+		t.Fatalf("did not get expected output, got: %+v", lines)
+	}
+}
+
+func TestRunAppendsLine(t *testing.T) {
+	_, lines, _ := runCLI(t, "x\n", "echo", "-n")
+	if len(lines) != 1 || lines[0].Result.Stdout != "x" {
+		t.Fatalf("expected the line as the last argument, got: %+v", lines)
+	}
+}
+
+func TestRunMapsExitCodes(t *testing.T) {
+	code, lines, _ := runCLI(t, "0\n3\n75\n", "-retries", "2", "-retry-codes", "75", "sh", "-c", "exit {}")
+	if code != 1 || len(lines) != 3 {
+		t.Fatalf("expected exit code 1 with 3 lines, got code: %d, lines: %+v", code, lines)
+	}
+	expected := []struct {
+		status   string
+		exitCode int
+		retries  int64
+	}{{"success", 0, 0}, {"failure", 3, 0}, {"failure", 75, 2}}
+	for i, e := range expected {
+		if lines[i].Status != e.status || lines[i].Result.ExitCode != e.exitCode || lines[i].Retries != e.retries {
+			t.Fatalf("did not get expected result for line %d, expected: %+v, got: %+v", i, e, lines[i])
+		}
+	}
+}
+
+func TestRunKillsOnTimeout(t *testing.T) {
+	code, lines, _ := runCLI(t, "5\n", "-timeout", "50ms", "sleep")
+	if code != 1 || len(lines) != 1 || lines[0].Status != "failure" || lines[0].Result.Error == "" {
+		t.Fatalf("expected the command to be killed and fail after retries, got code: %d, lines: %+v", code, lines)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	if code, _, stderr := runCLI(t, ""); code != 2 || !strings.Contains(stderr, "usage") {
+		t.Fatalf("expected usage with exit code 2, got: %d, %s", code, stderr)
+	}
+	if code, _, _ := runCLI(t, "", "-retry-codes", "x", "true"); code != 2 {
+		t.Fatalf("expected exit code 2 for invalid retry codes, got: %d", code)
+	}
+}
+
+func TestExecArgs(t *testing.T) {
+	args := execArgs([]string{"sh", "-c", "echo {#} {} {{x}}"})
+	expected := []string{"sh", "-c", `echo {{.ID}} {{.Body}} {{"{{"}}x{{"}}"}}`}
+	if strings.Join(args, "|") != strings.Join(expected, "|") {
+		t.Fatalf("did not get expected args, expected: %q, got: %q", expected, args)
+	}
+	if args := execArgs([]string{"echo"}); len(args) != 2 || args[1] != "{{.Body}}" {
+		t.Fatalf("expected the line to be appended, got: %q", args)
+	}
+}
+
+func TestRunKeepsLiteralBraces(t *testing.T) {
+	_, lines, _ := runCLI(t, "x\n", "echo", "{{", "{}", "}}")
+	if len(lines) != 1 || lines[0].Result.Stdout != "{{ x }}\n" {
+		t.Fatalf("expected literal braces in the command, got: %+v", lines)
+	}
+}
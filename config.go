@@ -0,0 +1,124 @@
+package gofherd
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Config is the part of the configuration which can be changed while the herd runs,
+// using the setters or `PATCH /config`.
+type Config struct {
+	// MaxRetries is the maximum number of times a Work unit will be retried before giving up.
+	MaxRetries int64
+	// RetryBackoff is the delay before a Work unit returned with Retry is tried again.
+	RetryBackoff time.Duration
+	// RateLimit is the maximum number of attempts started per second across the herd, 0 is unlimited.
+	RateLimit float64
+	// WorkTimeout is the deadline of the context passed to the processing logic for each attempt,
+	// 0 is no deadline. An attempt exceeding it is treated as Retry, unless the processing
+	// logic still returns Success or Failure.
+	WorkTimeout time.Duration
+}
+
+func (c Config) validate() error {
+	if c.MaxRetries < 0 {
+		return errors.New("max retries cannot be negative")
+	}
+	if c.RetryBackoff < 0 {
+		return errors.New("retry backoff cannot be negative")
+	}
+	if c.RateLimit < 0 {
+		return errors.New("rate limit cannot be negative")
+	}
+	if c.WorkTimeout < 0 {
+		return errors.New("work timeout cannot be negative")
+	}
+	return nil
+}
+
+// Config returns the current runtime configuration.
+func (gf *Gofherd) Config() Config {
+	return Config{
+		MaxRetries:   atomic.LoadInt64(&(gf.maxRetries)),
+		RetryBackoff: time.Duration(atomic.LoadInt64(&(gf.retryBackoff))),
+		RateLimit:    gf.limiter.getRate(),
+		WorkTimeout:  time.Duration(atomic.LoadInt64(&(gf.workTimeout))),
+	}
+}
+
+// SetConfig validates and applies the runtime configuration. It is safe to call while the herd runs.
+func (gf *Gofherd) SetConfig(c Config) error {
+	return gf.updateConfig(func(current *Config) { *current = c })
+}
+
+// SetRetryBackoff sets the delay before a Work unit returned with Retry is tried again.
+func (gf *Gofherd) SetRetryBackoff(backoff time.Duration) error {
+	return gf.updateConfig(func(c *Config) { c.RetryBackoff = backoff })
+}
+
+// SetRateLimit sets the maximum number of attempts started per second across the herd, 0 is unlimited.
+func (gf *Gofherd) SetRateLimit(perSecond float64) error {
+	return gf.updateConfig(func(c *Config) { c.RateLimit = perSecond })
+}
+
+// SetWorkTimeout sets the deadline of each attempt of the processing logic, 0 is no deadline.
+func (gf *Gofherd) SetWorkTimeout(timeout time.Duration) error {
+	return gf.updateConfig(func(c *Config) { c.WorkTimeout = timeout })
+}
+
+func (gf *Gofherd) updateConfig(update func(*Config)) error {
+	gf.configMu.Lock()
+	defer gf.configMu.Unlock()
+	c := gf.Config()
+	update(&c)
+	if err := c.validate(); err != nil {
+		gf.log.warn("rejected config update", Field{"reason", err.Error()})
+		return err
+	}
+	atomic.StoreInt64(&(gf.maxRetries), c.MaxRetries)
+	atomic.StoreInt64(&(gf.retryBackoff), int64(c.RetryBackoff))
+	if c.RateLimit != gf.limiter.getRate() {
+		gf.limiter.setRate(c.RateLimit)
+	}
+	atomic.StoreInt64(&(gf.workTimeout), int64(c.WorkTimeout))
+	gf.log.info("updated config",
+		Field{"max_retries", c.MaxRetries},
+		Field{"retry_backoff", c.RetryBackoff},
+		Field{"rate_limit", c.RateLimit},
+		Field{"work_timeout", c.WorkTimeout})
+	return nil
+}
+
+// configPatch is the body of `PATCH /config`, fields which are not set are left unchanged.
+type configPatch struct {
+	MaxRetries   *int64   `json:"max_retries"`
+	RetryBackoff *string  `json:"retry_backoff"`
+	RateLimit    *float64 `json:"rate_limit"`
+	WorkTimeout  *string  `json:"work_timeout"`
+}
+
+func (p configPatch) apply(c Config) (Config, error) {
+	if p.MaxRetries != nil {
+		c.MaxRetries = *p.MaxRetries
+	}
+	if p.RetryBackoff != nil {
+		backoff, err := time.ParseDuration(*p.RetryBackoff)
+		if err != nil {
+			return c, fmt.Errorf("invalid retry backoff: %s", err)
+		}
+		c.RetryBackoff = backoff
+	}
+	if p.RateLimit != nil {
+		c.RateLimit = *p.RateLimit
+	}
+	if p.WorkTimeout != nil {
+		timeout, err := time.ParseDuration(*p.WorkTimeout)
+		if err != nil {
+			return c, fmt.Errorf("invalid work timeout: %s", err)
+		}
+		c.WorkTimeout = timeout
+	}
+	return c, nil
+}
@@ -0,0 +1,62 @@
+package gofherd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetConfigValidates(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	for _, c := range []Config{
+		{MaxRetries: -1},
+		{RetryBackoff: -time.Second},
+		{RateLimit: -1},
+		{WorkTimeout: -time.Second},
+	} {
+		if err := gf.SetConfig(c); err == nil {
+			t.Fatalf("expected an error for invalid config: %+v", c)
+		}
+	}
+
+	expected := Config{MaxRetries: 3, RetryBackoff: time.Second, RateLimit: 5, WorkTimeout: time.Minute}
+	if err := gf.SetConfig(expected); err != nil {
+		t.Fatalf("unexpected error for valid config: %s", err)
+	}
+	if c := gf.Config(); c != expected {
+		t.Fatalf("did not get expected config, expected: %+v, got: %+v", expected, c)
+	}
+}
+
+func TestConfigSetters(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	gf.SetMaxRetries(4)
+	gf.SetRetryBackoff(time.Second)
+	gf.SetRateLimit(10)
+	gf.SetWorkTimeout(time.Minute)
+
+	expected := Config{MaxRetries: 4, RetryBackoff: time.Second, RateLimit: 10, WorkTimeout: time.Minute}
+	if c := gf.Config(); c != expected {
+		t.Fatalf("did not get expected config, expected: %+v, got: %+v", expected, c)
+	}
+	if err := gf.SetRetryBackoff(-time.Second); err == nil || gf.Config().RetryBackoff != time.Second {
+		t.Fatalf("expected negative backoff to be rejected and not applied")
+	}
+}
+
+func TestConfigPatchApply(t *testing.T) {
+	maxRetries := int64(7)
+	backoff := "250ms"
+	c, err := configPatch{MaxRetries: &maxRetries, RetryBackoff: &backoff}.apply(Config{RateLimit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := Config{MaxRetries: 7, RetryBackoff: 250 * time.Millisecond, RateLimit: 2}
+	if c != expected {
+		t.Fatalf("did not get expected config, expected: %+v, got: %+v", expected, c)
+	}
+
+	invalid := "soon"
+	if _, err := (configPatch{WorkTimeout: &invalid}).apply(Config{}); err == nil {
+		t.Fatalf("expected an error for an invalid duration")
+	}
+}
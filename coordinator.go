@@ -0,0 +1,310 @@
+package gofherd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultLeaseTimeout is how long a remote worker holds a lease without a heartbeat.
+	defaultLeaseTimeout = 30 * time.Second
+	// maxLeaseWait is the longest a `POST /lease` waits for Work to become available.
+	maxLeaseWait = 30 * time.Second
+)
+
+// CoordinatorOptions configures the herd returned by NewCoordinator.
+type CoordinatorOptions struct {
+	// LeaseTimeout is how long a remote worker holds Work without a heartbeat before it
+	// is returned to the retry path, it defaults to 30 seconds.
+	LeaseTimeout time.Duration
+}
+
+// Lease is the Work handed to a remote worker by `POST /lease`.
+type Lease struct {
+	LeaseID string          `json:"lease_id"`
+	WorkID  string          `json:"work_id"`
+	Body    json.RawMessage `json:"body"`
+	Attempt int64           `json:"attempt"`
+	Expires time.Time       `json:"expires"`
+}
+
+// Completion is the outcome of a lease reported by a remote worker with `POST /complete`.
+type Completion struct {
+	LeaseID string          `json:"lease_id"`
+	Status  string          `json:"status"`
+	Result  json.RawMessage `json:"result,omitempty"`
+}
+
+type leaseRequest struct {
+	LeaseID string `json:"lease_id"`
+}
+
+// lease is Work offered to or held by a remote worker.
+type lease struct {
+	Lease
+	mu      sync.Mutex
+	settled bool
+	held    chan struct{}
+	outcome chan Completion
+}
+
+func (l *lease) expiry() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.Expires
+}
+
+// extend moves the expiry of the lease, unless it has expired or been settled.
+func (l *lease) extend(now, expires time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.settled || !now.Before(l.Expires) {
+		return false
+	}
+	l.Expires = expires
+	return true
+}
+
+// complete settles the lease with a completion, unless it has expired or been settled.
+func (l *lease) complete(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.settled || !now.Before(l.Expires) {
+		return false
+	}
+	l.settled = true
+	return true
+}
+
+// expire settles the lease without a completion. It returns false if a completion came first,
+// which is then sent on the outcome chan.
+func (l *lease) expire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.settled {
+		return false
+	}
+	l.settled = true
+	return true
+}
+
+// coordinator hands the Work of the gophers to remote workers, and waits for their outcome.
+type coordinator struct {
+	timeout time.Duration
+	offers  chan *lease
+	mu      sync.Mutex
+	leases  map[string]*lease
+}
+
+// NewCoordinator returns a herd whose Work is processed by remote workers, see Worker.
+// Each gopher offers its Work to the workers over HTTP and waits for the outcome, so
+// the herd size bounds the number of leases held at once, and retries, callbacks and
+// OutputChan work like for local processing logic. Remote workers use these handlers,
+// served by Start along with the control handlers:
+//
+//   - `POST /lease?wait=10s` returns a Lease, or 204 if no Work is available within the wait.
+//   - `POST /heartbeat` with `{"lease_id": ...}` extends the lease, 404 means it is lost.
+//   - `POST /complete` with a Completion reports the status and the JSON result of the Work.
+//
+// A lease which is not extended within the lease timeout is returned to the retry path,
+// and a late heartbeat or completion for it is rejected with 409, or 404 once it is forgotten. The Work Body must be JSON encodable,
+// and its Result on the coordinator is the json.RawMessage reported by the worker.
+func NewCoordinator(opts CoordinatorOptions) *Gofherd {
+	if opts.LeaseTimeout <= 0 {
+		opts.LeaseTimeout = defaultLeaseTimeout
+	}
+	c := &coordinator{timeout: opts.LeaseTimeout, offers: make(chan *lease), leases: make(map[string]*lease)}
+	gf := NewWithContext(c.process)
+	gf.Handle("/lease", http.HandlerFunc(c.leaseHandler))
+	gf.Handle("/heartbeat", http.HandlerFunc(c.heartbeatHandler))
+	gf.Handle("/complete", http.HandlerFunc(c.completeHandler))
+	return gf
+}
+
+func newLeaseID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate a lease ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+var completionStatuses = map[string]Status{
+	Success.String(): Success,
+	Retry.String():   Retry,
+	Failure.String(): Failure,
+}
+
+// process offers the Work to the remote workers and waits for its outcome.
+// An expired lease, or Work which cannot be encoded, is retried.
+func (c *coordinator) process(ctx context.Context, w *Work) Status {
+	body, err := json.Marshal(w.Body)
+	if err != nil {
+		w.SetResult(err.Error())
+		return Failure
+	}
+	id, err := newLeaseID()
+	if err != nil {
+		w.SetResult(err.Error())
+		return Retry
+	}
+	l := &lease{Lease: Lease{LeaseID: id, WorkID: w.ID, Body: body, Attempt: w.Retries() + 1}, held: make(chan struct{}), outcome: make(chan Completion, 1)}
+	select {
+	case c.offers <- l:
+	case <-ctx.Done():
+		return Retry
+	}
+	<-l.held
+	defer c.release(l.LeaseID)
+
+	timer := time.NewTimer(time.Until(l.expiry()))
+	defer timer.Stop()
+	for {
+		select {
+		case outcome := <-l.outcome:
+			w.SetResult(outcome.Result)
+			return completionStatuses[outcome.Status]
+		case <-ctx.Done():
+			if l.expire() {
+				return Retry
+			}
+		case <-timer.C:
+			remaining := time.Until(l.expiry())
+			if remaining > 0 {
+				timer.Reset(remaining)
+			} else if l.expire() {
+				w.SetResult(json.RawMessage(`"lease expired"`))
+				return Retry
+			}
+		}
+	}
+}
+
+// hold records the lease handed to a worker, starting its expiry.
+func (c *coordinator) hold(l *lease) {
+	l.mu.Lock()
+	l.Expires = time.Now().Add(c.timeout)
+	l.mu.Unlock()
+	c.mu.Lock()
+	c.leases[l.LeaseID] = l
+	c.mu.Unlock()
+	close(l.held)
+}
+
+// release forgets the lease, so that late heartbeats and completions are rejected.
+func (c *coordinator) release(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.leases, id)
+}
+
+func (c *coordinator) get(id string) (*lease, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.leases[id]
+	return l, ok
+}
+
+func (c *coordinator) leaseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	wait := time.Duration(0)
+	if value := r.URL.Query().Get("wait"); value != "" {
+		var err error
+		if wait, err = time.ParseDuration(value); err != nil || wait < 0 {
+			writeJSON(w, http.StatusBadRequest, message{Msg: "invalid wait"})
+			return
+		}
+	}
+	if wait > maxLeaseWait {
+		wait = maxLeaseWait
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case l := <-c.offers:
+		// hold it before responding, so that a fast heartbeat or completion finds it
+		c.hold(l)
+		l.mu.Lock()
+		response := l.Lease
+		l.mu.Unlock()
+		writeJSON(w, http.StatusOK, response)
+	case <-timer.C:
+		w.WriteHeader(http.StatusNoContent)
+	case <-r.Context().Done():
+	}
+}
+
+func (c *coordinator) heartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req leaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, message{Msg: "invalid request"})
+		return
+	}
+	l, ok := c.get(req.LeaseID)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, message{Msg: "lease not found"})
+		return
+	}
+	now := time.Now()
+	expires := now.Add(c.timeout)
+	if !l.extend(now, expires) {
+		writeJSON(w, http.StatusConflict, message{Msg: "lease expired"})
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Expires time.Time `json:"expires"`
+	}{expires})
+}
+
+func (c *coordinator) completeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var completion Completion
+	if err := json.NewDecoder(r.Body).Decode(&completion); err != nil {
+		writeJSON(w, http.StatusBadRequest, message{Msg: "invalid request"})
+		return
+	}
+	if _, ok := completionStatuses[completion.Status]; !ok {
+		writeJSON(w, http.StatusBadRequest, message{Msg: "status must be success, retry or failure"})
+		return
+	}
+	c.mu.Lock()
+	l, ok := c.leases[completion.LeaseID]
+	// completion and expiry are decided by the lease, so that exactly one of them wins
+	completed := ok && l.complete(time.Now())
+	if completed {
+		delete(c.leases, completion.LeaseID)
+	}
+	c.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, message{Msg: "lease not found"})
+		return
+	}
+	if !completed {
+		writeJSON(w, http.StatusConflict, message{Msg: "lease expired"})
+		return
+	}
+	l.outcome <- completion
+	writeJSON(w, http.StatusOK, message{Msg: "success"})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
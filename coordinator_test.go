@@ -0,0 +1,208 @@
+package gofherd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func startCoordinator(opts CoordinatorOptions, herdSize int64, works []Work, t *testing.T) (*Gofherd, *httptest.Server) {
+	gf := NewCoordinator(opts)
+	gf.SetHerdSize(herdSize)
+	gf.SetMaxRetries(3)
+	gf.DisableServer()
+	server := httptest.NewServer(gf.Handler())
+	t.Cleanup(server.Close)
+	go func() {
+		for _, w := range works {
+			gf.SendWork(w)
+		}
+		gf.CloseInputChan()
+	}()
+	if err := gf.Start(); err != nil {
+		t.Fatalf("could not start coordinator: %s", err)
+	}
+	return gf, server
+}
+
+func postJSON(url string, body interface{}, v interface{}, t *testing.T) int {
+	payload, _ := json.Marshal(body)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("could not post to %s: %s", url, err)
+	}
+	defer resp.Body.Close()
+	if v != nil && resp.StatusCode == http.StatusOK {
+		json.NewDecoder(resp.Body).Decode(v)
+	}
+	return resp.StatusCode
+}
+
+func TestCoordinatorWithWorkers(t *testing.T) {
+	works := []Work{}
+	for i := 0; i < 20; i++ {
+		works = append(works, Work{ID: fmt.Sprintf("%d", i), Body: map[string]int{"n": i}})
+	}
+	gf, server := startCoordinator(CoordinatorOptions{}, 4, works, t)
+	var mu sync.Mutex
+	successes := 0
+	gf.AddSuccessCallback(func(w *Work) {
+		mu.Lock()
+		defer mu.Unlock()
+		successes++
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		worker := &Worker{URL: server.URL, Process: func(ctx context.Context, w *Work) Status {
+			var body struct{ N int }
+			json.Unmarshal(w.Body.(json.RawMessage), &body)
+			w.SetResult(body.N * 2)
+			if body.N%5 == 0 && w.Retries() == 0 {
+				return Retry
+			}
+			if body.N == 7 {
+				return Failure
+			}
+			return Success
+		}}
+		go worker.Run(ctx)
+	}
+
+	for work := range gf.OutputChan() {
+		var n int
+		fmt.Sscanf(work.ID, "%d", &n)
+		var result int
+		json.Unmarshal(work.Result().(json.RawMessage), &result)
+		if result != n*2 {
+			t.Fatalf("expected result %d for work %s, got: %d", n*2, work.ID, result)
+		}
+		expectedStatus, expectedRetries := Success, int64(0)
+		if n == 7 {
+			expectedStatus = Failure
+		}
+		if n%5 == 0 {
+			expectedRetries = 1
+		}
+		if work.Status() != expectedStatus || work.Retries() != expectedRetries {
+			t.Fatalf("expected %s after %d retries for work %s, got: %s after %d", expectedStatus, expectedRetries, work.ID, work.Status(), work.Retries())
+		}
+	}
+	if successes != 19 {
+		t.Fatalf("expected 19 success callbacks, got: %d", successes)
+	}
+}
+
+func TestCoordinatorLeaseExpires(t *testing.T) {
+	gf, server := startCoordinator(CoordinatorOptions{LeaseTimeout: 50 * time.Millisecond}, 1, []Work{{ID: "a", Body: "x"}}, t)
+
+	var first Lease
+	if code := postJSON(server.URL+"/lease?wait=1s", nil, &first, t); code != http.StatusOK || first.WorkID != "a" || string(first.Body) != `"x"` || first.Attempt != 1 {
+		t.Fatalf("expected a lease for work a, got: %d, %+v", code, first)
+	}
+	var second Lease
+	if code := postJSON(server.URL+"/lease?wait=5s", nil, &second, t); code != http.StatusOK || second.WorkID != "a" || second.Attempt != 2 {
+		t.Fatalf("expected work a to be leased again after expiry, got: %d, %+v", code, second)
+	}
+	if code := postJSON(server.URL+"/heartbeat", leaseRequest{LeaseID: first.LeaseID}, nil, t); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a heartbeat on an expired lease, got: %d", code)
+	}
+	if code := postJSON(server.URL+"/complete", Completion{LeaseID: first.LeaseID, Status: "success"}, nil, t); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for completing an expired lease, got: %d", code)
+	}
+	if code := postJSON(server.URL+"/complete", Completion{LeaseID: second.LeaseID, Status: "done"}, nil, t); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid status, got: %d", code)
+	}
+	if code := postJSON(server.URL+"/complete", Completion{LeaseID: second.LeaseID, Status: "failure", Result: json.RawMessage(`"boom"`)}, nil, t); code != http.StatusOK {
+		t.Fatalf("expected 200 for completing the lease, got: %d", code)
+	}
+
+	work := <-gf.OutputChan()
+	if work.Status() != Failure || work.Retries() != 1 || string(work.Result().(json.RawMessage)) != `"boom"` {
+		t.Fatalf("expected failure after 1 retry, got: %s after %d, result: %v", work.Status(), work.Retries(), work.Result())
+	}
+	if code := postJSON(server.URL+"/lease?wait=10ms", nil, nil, t); code != http.StatusNoContent {
+		t.Fatalf("expected 204 without work, got: %d", code)
+	}
+}
+
+func TestCoordinatorCompletionRacesExpiry(t *testing.T) {
+	c := &coordinator{timeout: time.Minute, leases: make(map[string]*lease)}
+	server := httptest.NewServer(http.HandlerFunc(c.completeHandler))
+	defer server.Close()
+	heartbeat := httptest.NewServer(http.HandlerFunc(c.heartbeatHandler))
+	defer heartbeat.Close()
+
+	// the lease has expired, but the gopher holding it has not noticed yet
+	expired := &lease{Lease: Lease{LeaseID: "a", Expires: time.Now().Add(-time.Millisecond)}, outcome: make(chan Completion, 1)}
+	c.leases["a"] = expired
+	if code := postJSON(heartbeat.URL, leaseRequest{LeaseID: "a"}, nil, t); code != http.StatusConflict {
+		t.Fatalf("expected 409 for a heartbeat on an expired lease, got: %d", code)
+	}
+	if code := postJSON(server.URL, Completion{LeaseID: "a", Status: "success"}, nil, t); code != http.StatusConflict {
+		t.Fatalf("expected 409 for a completion after expiry, got: %d", code)
+	}
+	if !expired.expire() {
+		t.Fatalf("expected the expiry to win over the late completion")
+	}
+
+	live := &lease{Lease: Lease{LeaseID: "b", Expires: time.Now().Add(time.Minute)}, outcome: make(chan Completion, 1)}
+	c.leases["b"] = live
+	if code := postJSON(server.URL, Completion{LeaseID: "b", Status: "success"}, nil, t); code != http.StatusOK {
+		t.Fatalf("expected 200 for a completion in time, got: %d", code)
+	}
+	if live.expire() {
+		t.Fatalf("expected the completion to win over a later expiry")
+	}
+	if outcome := <-live.outcome; outcome.Status != "success" {
+		t.Fatalf("expected the completion to be delivered, got: %+v", outcome)
+	}
+}
+
+func TestWorkerHeartbeatKeepsLease(t *testing.T) {
+	gf, server := startCoordinator(CoordinatorOptions{LeaseTimeout: 100 * time.Millisecond}, 1, []Work{{ID: "slow"}}, t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker := &Worker{URL: server.URL, HeartbeatInterval: 20 * time.Millisecond, Process: func(ctx context.Context, w *Work) Status {
+		select {
+		case <-time.After(300 * time.Millisecond):
+			return Success
+		case <-ctx.Done():
+			return Retry
+		}
+	}}
+	go worker.Run(ctx)
+
+	work := <-gf.OutputChan()
+	if work.Status() != Success || work.Retries() != 0 {
+		t.Fatalf("expected success without retries, got: %s after %d retries", work.Status(), work.Retries())
+	}
+}
+
+func TestWorkerUsesAuthToken(t *testing.T) {
+	gf := NewCoordinator(CoordinatorOptions{})
+	gf.SetAuthToken("secret")
+	server := httptest.NewServer(gf.Handler())
+	defer server.Close()
+
+	errs := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker := &Worker{URL: server.URL, Process: func(ctx context.Context, w *Work) Status { return Success }, OnError: func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}}
+	go worker.Run(ctx)
+	if err := <-errs; err == nil {
+		t.Fatalf("expected an error without the auth token")
+	}
+}
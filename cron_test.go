@@ -0,0 +1,59 @@
+package gofherd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2024, time.January, 31, 10, 7, 30, 0, time.UTC) // a Wednesday
+	for _, tc := range []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2024, time.January, 31, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, time.January, 31, 10, 15, 0, 0, time.UTC)},
+		{"5 * * * *", time.Date(2024, time.January, 31, 11, 5, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2024, time.January, 31, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"30 8 * * mon-fri", time.Date(2024, time.February, 1, 8, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, time.February, 4, 0, 0, 0, 0, time.UTC)},
+		// either day field matches when both are restricted
+		{"0 0 15 * sat", time.Date(2024, time.February, 3, 0, 0, 0, 0, time.UTC)},
+		// a stepped `*` still restricts the days to those matching both fields, like in cron
+		{"0 0 */2 * mon", time.Date(2024, time.February, 5, 0, 0, 0, 0, time.UTC)},
+		{"10,20 10 * * *", time.Date(2024, time.January, 31, 10, 10, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, time.January, 31, 11, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	} {
+		cs, err := parseCron(tc.expr, time.UTC)
+		if err != nil {
+			t.Fatalf("could not parse %q: %s", tc.expr, err)
+		}
+		if next := cs.next(from); !next.Equal(tc.expected) {
+			t.Fatalf("expected next run of %q to be %s, got: %s", tc.expr, tc.expected, next)
+		}
+	}
+}
+
+func TestCronNextInLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+5", 5*60*60)
+	cs, err := parseCron("0 9 * * *", loc)
+	if err != nil {
+		t.Fatalf("could not parse: %s", err)
+	}
+	next := cs.next(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	if expected := time.Date(2024, time.January, 1, 4, 0, 0, 0, time.UTC); !next.Equal(expected) {
+		t.Fatalf("expected %s, got: %s", expected, next)
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "5-1 * * * *", "*/0 * * * *", "* * * foo *", "@reboot"} {
+		if _, err := parseCron(expr, time.UTC); err == nil {
+			t.Fatalf("expected an error parsing %q", expr)
+		}
+	}
+}
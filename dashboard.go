@@ -0,0 +1,110 @@
+package gofherd
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// recentFailuresLimit is the number of failed Work units shown on the dashboard.
+const recentFailuresLimit = 20
+
+// dashboardInterval is how often the dashboard receives a new state.
+var dashboardInterval = time.Second
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// FailedWork is a Work unit which has reached the Failure status.
+type FailedWork struct {
+	ID      string    `json:"id"`
+	Retries int64     `json:"retries"`
+	Failed  time.Time `json:"failed"`
+}
+
+// failureLog keeps the most recent failures, newest first.
+type failureLog struct {
+	mu      sync.Mutex
+	entries []FailedWork
+	now     func() time.Time
+}
+
+func newFailureLog() *failureLog {
+	return &failureLog{now: time.Now}
+}
+
+func (fl *failureLog) add(work *Work) {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	entry := FailedWork{ID: work.ID, Retries: work.retryCount(), Failed: fl.now()}
+	fl.entries = append([]FailedWork{entry}, fl.entries...)
+	if len(fl.entries) > recentFailuresLimit {
+		fl.entries = fl.entries[:recentFailuresLimit]
+	}
+}
+
+func (fl *failureLog) recent() []FailedWork {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	return append([]FailedWork{}, fl.entries...)
+}
+
+type dashboardState struct {
+	Herd           int64          `json:"herd"`
+	Done           bool           `json:"done"`
+	Progress       progress       `json:"progress"`
+	InFlight       []InFlightWork `json:"in_flight"`
+	RecentFailures []FailedWork   `json:"recent_failures"`
+}
+
+func (gf *Gofherd) dashboardState() dashboardState {
+	return dashboardState{
+		Herd:           gf.HerdSize().Target,
+		Done:           gf.output.closed(),
+		Progress:       newProgressResponse(gf.Progress()),
+		InFlight:       gf.InFlight(),
+		RecentFailures: gf.failures.recent(),
+	}
+}
+
+func (gf *Gofherd) dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
+
+// dashboardEventsHandler streams the dashboard state as server-sent events,
+// until the client goes away. The last state is sent once processing is complete.
+func (gf *Gofherd) dashboardEventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	ticker := time.NewTicker(dashboardInterval)
+	defer ticker.Stop()
+	for {
+		state, _ := json.Marshal(gf.dashboardState())
+		fmt.Fprintf(w, "data: %s\n\n", state)
+		flusher.Flush()
+		select {
+		case <-r.Context().Done():
+			return
+		case <-gf.done:
+			state, _ = json.Marshal(gf.dashboardState())
+			fmt.Fprintf(w, "data: %s\n\n", state)
+			flusher.Flush()
+			return
+		case <-ticker.C:
+		}
+	}
+}
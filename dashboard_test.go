@@ -0,0 +1,128 @@
+package gofherd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFailureLogKeepsMostRecent(t *testing.T) {
+	fl := newFailureLog()
+	for i := 0; i < recentFailuresLimit+5; i++ {
+		fl.add(&Work{ID: fmt.Sprintf("%d", i)})
+	}
+	recent := fl.recent()
+	if len(recent) != recentFailuresLimit {
+		t.Fatalf("expected %d recent failures, got: %d", recentFailuresLimit, len(recent))
+	}
+	if recent[0].ID != fmt.Sprintf("%d", recentFailuresLimit+4) {
+		t.Fatalf("expected newest failure first, got: %s", recent[0].ID)
+	}
+}
+
+func TestDashboardGet(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	resp := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/dashboard", nil)
+	if err != nil {
+		t.Fatalf("failed to create a request")
+	}
+	gf.Handler().ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK || !strings.HasPrefix(resp.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("handler returned unexpected response: got %v %v", resp.Code, resp.Header().Get("Content-Type"))
+	}
+	if strings.Contains(resp.Body.String(), "http://") || strings.Contains(resp.Body.String(), "https://") {
+		t.Fatalf("expected dashboard to not reference external assets")
+	}
+}
+
+func TestDashboardEventsStreamsState(t *testing.T) {
+	gf := getBasicGopherd(0, 3, 1, Failure)
+	gf.DisableServer()
+	gf.Start()
+	for range gf.OutputChan() {
+	}
+
+	server := httptest.NewServer(gf.Handler())
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/dashboard/events")
+	if err != nil {
+		t.Fatalf("could not get events: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("did not get expected content type, got: %s", resp.Header.Get("Content-Type"))
+	}
+
+	var body strings.Builder
+	buf := make([]byte, 4096)
+	for {
+		n, err := resp.Body.Read(buf)
+		body.Write(buf[:n])
+		if err != nil {
+			break
+		}
+	}
+	events := strings.Split(strings.TrimSpace(body.String()), "\n\n")
+	var state dashboardState
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(events[len(events)-1], "data: ")), &state); err != nil {
+		t.Fatalf("could not decode event: %s", err)
+	}
+	if !state.Done || state.Progress.Failure != 3 || len(state.RecentFailures) != 3 {
+		t.Fatalf("did not get expected state, got: %+v", state)
+	}
+}
+
+func TestDashboardTokenCookie(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	gf.SetAuthToken("s3cret")
+	handler := gf.Handler()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/dashboard?token=wrong", nil)
+	handler.ServeHTTP(resp, req)
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a wrong token to be rejected, got: %v", resp.Code)
+	}
+
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/dashboard?token=s3cret", nil)
+	handler.ServeHTTP(resp, req)
+	if resp.Code != http.StatusSeeOther || resp.Header().Get("Location") != "dashboard" {
+		t.Fatalf("expected a redirect dropping the token, got: %v %s", resp.Code, resp.Header().Get("Location"))
+	}
+	cookies := resp.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != authCookie || !cookies[0].HttpOnly {
+		t.Fatalf("expected an http only token cookie, got: %v", cookies)
+	}
+
+	// the requests made by the dashboard page only carry the cookie
+	for _, tc := range []struct {
+		method, path string
+		body         string
+	}{
+		{"GET", "/dashboard", ""},
+		{"GET", "/herd", ""},
+		{"PATCH", "/herd", `{"num": 0}`},
+	} {
+		resp = httptest.NewRecorder()
+		req, _ = http.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.AddCookie(cookies[0])
+		handler.ServeHTTP(resp, req)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("expected %s %s with the cookie to be authorized, got: %v", tc.method, tc.path, resp.Code)
+		}
+	}
+
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/herd", nil)
+	req.AddCookie(&http.Cookie{Name: authCookie, Value: "wrong"})
+	handler.ServeHTTP(resp, req)
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a wrong cookie to be rejected, got: %v", resp.Code)
+	}
+}
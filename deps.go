@@ -0,0 +1,318 @@
+package gofherd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// CycleError is returned by SendWorkGraph for Work whose dependencies form a cycle.
+type CycleError struct {
+	// Path is the IDs of the cycle, starting and ending with the same ID.
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return "dependency cycle: " + strings.Join(e.Path, " -> ")
+}
+
+// heldWork is Work waiting for its dependencies to succeed.
+type heldWork struct {
+	work      Work
+	remaining map[string]bool
+}
+
+// completedLimit is the number of completed Work units whose status is remembered
+// for the Work depending on them.
+const completedLimit = 10000
+
+// dependencyGraph holds Work until its dependencies succeed. It remembers the status of
+// the last completed Work units, since dependents may be sent after their dependencies.
+type dependencyGraph struct {
+	// sendMu serializes sending Work with dependencies, so that SendWorkGraph checks
+	// for cycles and holds its Work without other Work getting in between
+	sendMu    sync.Mutex
+	mu        sync.Mutex
+	completed map[string]Status
+	order     []string
+	held      map[string]*heldWork
+	waiting   map[string][]string
+}
+
+func newDependencyGraph() *dependencyGraph {
+	return &dependencyGraph{
+		completed: make(map[string]Status),
+		held:      make(map[string]*heldWork),
+		waiting:   make(map[string][]string),
+	}
+}
+
+// cycle returns the cycle closed by Work with the ID and dependencies, if any. Besides
+// the held Work, extra has the dependencies of Work about to be sent, by ID.
+// It must be called with the lock held.
+func (dg *dependencyGraph) cycle(id string, deps []string, extra map[string][]string) []string {
+	visited := map[string]bool{}
+	var path []string
+	var visit func(string) bool
+	visit = func(node string) bool {
+		path = append(path, node)
+		if node == id {
+			return true
+		}
+		if !visited[node] {
+			visited[node] = true
+			next := extra[node]
+			if h, ok := dg.held[node]; ok {
+				next = h.work.DependsOn
+			}
+			for _, dep := range next {
+				if visit(dep) {
+					return true
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+	for _, dep := range deps {
+		if visit(dep) {
+			return append([]string{id}, path...)
+		}
+	}
+	return nil
+}
+
+// failedDependency returns the dependency which did not succeed, if any.
+// It must be called with the lock held.
+func (dg *dependencyGraph) failedDependency(work *Work) (string, Status, bool) {
+	for _, dep := range work.DependsOn {
+		if status, ok := dg.completed[dep]; ok && status != Success {
+			return dep, status, true
+		}
+	}
+	return "", 0, false
+}
+
+// hold decides what happens to Work sent with dependencies. It returns true if the Work
+// is ready to be processed, or sets a failure reason on it if it can never be processed.
+// Otherwise the Work is held until its dependencies complete.
+func (dg *dependencyGraph) hold(work *Work) bool {
+	dg.mu.Lock()
+	defer dg.mu.Unlock()
+	if _, ok := dg.held[work.ID]; ok {
+		work.reason = fmt.Sprintf("work %s is already waiting for its dependencies", work.ID)
+		return false
+	}
+	if path := dg.cycle(work.ID, work.DependsOn, nil); path != nil {
+		work.reason = (&CycleError{Path: path}).Error()
+		return false
+	}
+	if dep, status, failed := dg.failedDependency(work); failed {
+		work.reason = dependencyReason(dep, status)
+		return false
+	}
+	remaining := map[string]bool{}
+	for _, dep := range work.DependsOn {
+		if _, ok := dg.completed[dep]; !ok {
+			remaining[dep] = true
+		}
+	}
+	if len(remaining) == 0 {
+		return true
+	}
+	dg.held[work.ID] = &heldWork{work: *work, remaining: remaining}
+	for dep := range remaining {
+		dg.waiting[dep] = append(dg.waiting[dep], work.ID)
+	}
+	return false
+}
+
+func dependencyReason(dep string, status Status) string {
+	if status == Cancelled {
+		return fmt.Sprintf("dependency %s was cancelled", dep)
+	}
+	return fmt.Sprintf("dependency %s failed", dep)
+}
+
+// finished records the status of completed Work. It returns the held Work which is now
+// ready, and the held Work which failed because of it, with its failure reason set.
+func (dg *dependencyGraph) finished(id string, status Status) ([]Work, []Work) {
+	dg.mu.Lock()
+	defer dg.mu.Unlock()
+	if _, ok := dg.completed[id]; !ok {
+		dg.order = append(dg.order, id)
+		if len(dg.order) > completedLimit {
+			delete(dg.completed, dg.order[0])
+			dg.order = dg.order[1:]
+		}
+	}
+	dg.completed[id] = status
+	var ready, failed []Work
+	for _, dependent := range dg.waiting[id] {
+		h, ok := dg.held[dependent]
+		if !ok {
+			continue
+		}
+		if status != Success {
+			delete(dg.held, dependent)
+			h.work.reason = dependencyReason(id, status)
+			failed = append(failed, h.work)
+			continue
+		}
+		delete(h.remaining, id)
+		if len(h.remaining) == 0 {
+			delete(dg.held, dependent)
+			ready = append(ready, h.work)
+		}
+	}
+	delete(dg.waiting, id)
+	return ready, failed
+}
+
+// remove takes the held Work with the ID out of the graph.
+func (dg *dependencyGraph) remove(id string) (Work, bool) {
+	dg.mu.Lock()
+	defer dg.mu.Unlock()
+	h, ok := dg.held[id]
+	if !ok {
+		return Work{}, false
+	}
+	delete(dg.held, id)
+	return h.work, true
+}
+
+// EnableDependencies lets Work declare the IDs of the Work units it depends on in DependsOn.
+// Such Work is held until all its dependencies have succeeded, and it is emitted as a
+// Failure, with a FailureReason, if any of them fails or is cancelled, or if its
+// dependencies form a cycle. Dependencies may be sent before or after the Work depending
+// on them, so the herd remembers the status of the last 10000 completed Work units. Work
+// depending on an ID which is never sent, or was completed before those, is held forever,
+// and the herd does not finish. Work sent with SendWorkAt or SendWorkAfter is held once due.
+// It must be called before sending any Work.
+func (gf *Gofherd) EnableDependencies() {
+	gf.deps = newDependencyGraph()
+}
+
+// SendWorkGraph sends Work units which may depend on each other, and on Work sent before.
+// It returns a *CycleError, without sending any of them, if their dependencies form a cycle.
+// Like SendWork, it blocks until the Work which is ready has been picked up by gophers.
+func (gf *Gofherd) SendWorkGraph(works []Work) error {
+	if gf.deps == nil {
+		return fmt.Errorf("dependencies are not enabled, see EnableDependencies")
+	}
+	batch := make(map[string][]string, len(works))
+	for _, work := range works {
+		if _, ok := batch[work.ID]; ok {
+			return fmt.Errorf("work %s is sent twice", work.ID)
+		}
+		batch[work.ID] = work.DependsOn
+	}
+	gf.deps.sendMu.Lock()
+	defer gf.deps.sendMu.Unlock()
+	gf.deps.mu.Lock()
+	for _, work := range works {
+		if path := gf.deps.cycle(work.ID, work.DependsOn, batch); path != nil {
+			gf.deps.mu.Unlock()
+			return &CycleError{Path: path}
+		}
+	}
+	gf.deps.mu.Unlock()
+	for _, work := range works {
+		if len(work.DependsOn) > 0 {
+			gf.sendDependentLocked(context.Background(), work)
+		} else {
+			gf.SendWork(work)
+		}
+	}
+	return nil
+}
+
+// sendDependent holds Work with dependencies until they succeed.
+func (gf *Gofherd) sendDependent(ctx context.Context, work Work) {
+	if gf.deps != nil {
+		gf.deps.sendMu.Lock()
+		defer gf.deps.sendMu.Unlock()
+	}
+	gf.sendDependentLocked(ctx, work)
+}
+
+// sendDependentLocked is sendDependent, with the send lock held.
+func (gf *Gofherd) sendDependentLocked(ctx context.Context, work Work) {
+	gf.input.lock()
+	if gf.input.closed() {
+		gf.input.unlock()
+		panic("gofherd: SendWork called after CloseInputChan")
+	}
+	gf.input.increment()
+	gf.input.unlock()
+	gf.prepareWork(ctx, &work, StateWaiting)
+	if gf.dependOn(&work) {
+		gf.releaseDependent(work)
+	}
+}
+
+// dependOn returns true if the dependencies of the Work have succeeded. Otherwise the
+// Work is held until they do, or failed if they never can.
+func (gf *Gofherd) dependOn(work *Work) bool {
+	if gf.deps == nil {
+		work.reason = "dependencies are not enabled, see EnableDependencies"
+	} else if ready := gf.deps.hold(work); ready {
+		return true
+	}
+	if work.reason != "" {
+		gf.failDependent(*work)
+		return false
+	}
+	gf.log.debug("holding work for its dependencies", Field{"work_id", work.ID})
+	return false
+}
+
+// dueDependent decides what happens to scheduled Work with dependencies once it is due.
+// It returns true if the Work can be handed to the gophers.
+func (gf *Gofherd) dueDependent(work *Work) bool {
+	// cancelled Work is handed to the gophers right away, to be emitted as Cancelled
+	if gf.registry.isCancelled(work.ID) {
+		return true
+	}
+	if gf.deps != nil {
+		gf.deps.sendMu.Lock()
+		defer gf.deps.sendMu.Unlock()
+	}
+	gf.registry.held(work.ID)
+	return gf.dependOn(work)
+}
+
+// releaseDependent hands Work whose dependencies succeeded to the gophers.
+func (gf *Gofherd) releaseDependent(work Work) {
+	gf.registry.due(work.ID)
+	gf.log.debug("released work, dependencies succeeded", Field{"work_id", work.ID})
+	go func() {
+		select {
+		case gf.scheduler.due <- work:
+		case <-gf.done:
+		}
+	}()
+}
+
+// failDependent emits Work which can never be processed because of its dependencies.
+func (gf *Gofherd) failDependent(work Work) {
+	gf.log.warn("failed work, dependencies did not succeed", Field{"work_id", work.ID}, Field{"reason", work.reason})
+	work.setStatus(Failure)
+	// the output chan may not be read until SendWork returns
+	go gf.pushToOutputChan(work)
+}
+
+// resolveDependents releases or fails the Work depending on completed Work.
+func (gf *Gofherd) resolveDependents(work *Work) {
+	if gf.deps == nil {
+		return
+	}
+	ready, failed := gf.deps.finished(work.ID, work.Status())
+	for _, w := range ready {
+		gf.releaseDependent(w)
+	}
+	for _, w := range failed {
+		gf.failDependent(w)
+	}
+}
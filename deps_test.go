@@ -0,0 +1,293 @@
+package gofherd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func getDependentGopherd(processingLogic func(*Work) Status) *Gofherd {
+	gf := New(processingLogic)
+	gf.SetHerdSize(3)
+	gf.SetAddr("127.0.0.1:0")
+	gf.EnableDependencies()
+	return gf
+}
+
+func collectOutput(gf *Gofherd, t *testing.T) map[string]Work {
+	outputs := map[string]Work{}
+	for work := range gf.OutputChan() {
+		if _, ok := outputs[work.ID]; ok {
+			t.Fatalf("expected work %s to be emitted once", work.ID)
+		}
+		outputs[work.ID] = work
+	}
+	return outputs
+}
+
+func TestDependenciesRunInOrder(t *testing.T) {
+	var mu sync.Mutex
+	finished := map[string]time.Time{}
+	started := map[string]time.Time{}
+	gf := getDependentGopherd(func(w *Work) Status {
+		mu.Lock()
+		started[w.ID] = time.Now()
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		finished[w.ID] = time.Now()
+		mu.Unlock()
+		return Success
+	})
+	go func() {
+		// the dependent is sent first, it waits for its dependencies to be sent
+		gf.SendWork(Work{ID: "d", DependsOn: []string{"c"}})
+		err := gf.SendWorkGraph([]Work{
+			{ID: "c", DependsOn: []string{"a", "b"}},
+			{ID: "a"},
+			{ID: "b"},
+		})
+		if err != nil {
+			t.Errorf("could not send graph: %s", err)
+		}
+		gf.CloseInputChan()
+	}()
+	gf.Start()
+
+	outputs := collectOutput(gf, t)
+	if len(outputs) != 4 {
+		t.Fatalf("expected 4 work units, got: %d", len(outputs))
+	}
+	for id, work := range outputs {
+		if work.Status() != Success || work.FailureReason() != "" {
+			t.Fatalf("expected success for %s, got: %s (%s)", id, work.Status(), work.FailureReason())
+		}
+	}
+	for _, edge := range [][2]string{{"a", "c"}, {"b", "c"}, {"c", "d"}} {
+		if started[edge[1]].Before(finished[edge[0]]) {
+			t.Fatalf("expected %s to start after %s finished", edge[1], edge[0])
+		}
+	}
+	assertAllChannelsClosed(gf, t)
+}
+
+func TestDependencyFailurePropagates(t *testing.T) {
+	var mu sync.Mutex
+	processed := map[string]bool{}
+	gf := getDependentGopherd(func(w *Work) Status {
+		mu.Lock()
+		processed[w.ID] = true
+		mu.Unlock()
+		if w.ID == "a" {
+			return Failure
+		}
+		return Success
+	})
+	go func() {
+		gf.SendWorkGraph([]Work{
+			{ID: "a"},
+			{ID: "b", DependsOn: []string{"a"}},
+			{ID: "c", DependsOn: []string{"b"}},
+			{ID: "d"},
+		})
+		gf.CloseInputChan()
+	}()
+	gf.Start()
+
+	outputs := collectOutput(gf, t)
+	expected := map[string]struct {
+		status Status
+		reason string
+	}{
+		"a": {Failure, ""},
+		"b": {Failure, "dependency a failed"},
+		"c": {Failure, "dependency b failed"},
+		"d": {Success, ""},
+	}
+	for id, e := range expected {
+		work := outputs[id]
+		if work.Status() != e.status || work.FailureReason() != e.reason {
+			t.Fatalf("expected %s with reason %q for %s, got: %s with %q", e.status, e.reason, id, work.Status(), work.FailureReason())
+		}
+	}
+	if processed["b"] || processed["c"] {
+		t.Fatalf("expected dependents of failed work not to be processed, got: %v", processed)
+	}
+	b := outputs["b"]
+	if r := newRecord(&b); r.Reason != "dependency a failed" {
+		t.Fatalf("expected the sinks to write the reason, got: %+v", r)
+	}
+}
+
+func TestSendWorkGraphDetectsCycles(t *testing.T) {
+	gf := getDependentGopherd(func(w *Work) Status { return Success })
+	err := gf.SendWorkGraph([]Work{
+		{ID: "a", DependsOn: []string{"b"}},
+		{ID: "b", DependsOn: []string{"c"}},
+		{ID: "c", DependsOn: []string{"a"}},
+	})
+	var cycleErr *CycleError
+	if !errors.As(err, &cycleErr) || strings.Join(cycleErr.Path, ",") != "a,b,c,a" {
+		t.Fatalf("expected a cycle error, got: %v", err)
+	}
+	if submitted := gf.input.count(); submitted != 0 {
+		t.Fatalf("expected no work to be sent, got: %d", submitted)
+	}
+	if err := gf.SendWorkGraph([]Work{{ID: "a"}, {ID: "a"}}); err == nil {
+		t.Fatalf("expected an error for duplicate IDs")
+	}
+}
+
+func TestSendWorkFailsCycles(t *testing.T) {
+	gf := getDependentGopherd(func(w *Work) Status { return Success })
+	go func() {
+		gf.SendWork(Work{ID: "a", DependsOn: []string{"b"}})
+		gf.SendWork(Work{ID: "b", DependsOn: []string{"a"}})
+		gf.SendWork(Work{ID: "self", DependsOn: []string{"self"}})
+		gf.CloseInputChan()
+	}()
+	gf.Start()
+
+	outputs := collectOutput(gf, t)
+	for id, reason := range map[string]string{
+		"b":    "dependency cycle: b -> a -> b",
+		"a":    "dependency b failed",
+		"self": "dependency cycle: self -> self",
+	} {
+		if work := outputs[id]; work.Status() != Failure || work.FailureReason() != reason {
+			t.Fatalf("expected failure with reason %q for %s, got: %s with %q", reason, id, work.Status(), work.FailureReason())
+		}
+	}
+}
+
+func TestDependencyOnCompletedWork(t *testing.T) {
+	gf := getDependentGopherd(func(w *Work) Status { return Success })
+	gf.Start()
+	gf.SendWork(Work{ID: "a"})
+	if work := <-gf.OutputChan(); work.ID != "a" {
+		t.Fatalf("expected work a, got: %s", work.ID)
+	}
+	gf.SendWork(Work{ID: "b", DependsOn: []string{"a"}})
+	gf.CloseInputChan()
+	outputs := collectOutput(gf, t)
+	if work := outputs["b"]; work.Status() != Success {
+		t.Fatalf("expected work b to run after a completed, got: %s", work.Status())
+	}
+}
+
+func TestCancelWaitingWork(t *testing.T) {
+	gf := getDependentGopherd(func(w *Work) Status { return Success })
+	release := make(chan struct{})
+	gf.Start()
+	go func() {
+		gf.SendWork(Work{ID: "b", DependsOn: []string{"a"}})
+		gf.SendWork(Work{ID: "c", DependsOn: []string{"b"}})
+		<-release
+		gf.SendWork(Work{ID: "a"})
+		gf.CloseInputChan()
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for info, _ := gf.WorkInfo("c"); info.State != StateWaiting; info, _ = gf.WorkInfo("c") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected c to be waiting, got: %s", info.State)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := gf.Cancel("b"); err != nil {
+		t.Fatalf("could not cancel: %s", err)
+	}
+	close(release)
+
+	outputs := collectOutput(gf, t)
+	if work := outputs["b"]; work.Status() != Cancelled {
+		t.Fatalf("expected b to be cancelled, got: %s", work.Status())
+	}
+	if work := outputs["c"]; work.Status() != Failure || work.FailureReason() != "dependency b was cancelled" {
+		t.Fatalf("expected c to fail, got: %s with %q", work.Status(), work.FailureReason())
+	}
+	if work := outputs["a"]; work.Status() != Success {
+		t.Fatalf("expected a to succeed, got: %s", work.Status())
+	}
+}
+
+func TestDependenciesNotEnabled(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	gf.SetHerdSize(1)
+	gf.SetAddr("127.0.0.1:0")
+	go func() {
+		gf.SendWork(Work{ID: "a", DependsOn: []string{"b"}})
+		gf.CloseInputChan()
+	}()
+	gf.Start()
+	work := <-gf.OutputChan()
+	if work.Status() != Failure || !strings.Contains(work.FailureReason(), "EnableDependencies") {
+		t.Fatalf("expected a failure pointing to EnableDependencies, got: %s with %q", work.Status(), work.FailureReason())
+	}
+}
+
+func TestSendDependentAfterCloseInputChanPanics(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	gf.EnableDependencies()
+	hook := &countingHook{counts: make(map[string]int)}
+	gf.AddHook(hook)
+	gf.CloseInputChan()
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected SendWork after CloseInputChan to panic")
+		}
+		if _, ok := gf.WorkInfo("a"); ok || hook.counts["enqueue"] != 0 {
+			t.Fatalf("expected rejected work to not be recorded, enqueue hooks ran %d times", hook.counts["enqueue"])
+		}
+	}()
+	gf.SendWork(Work{ID: "a", DependsOn: []string{"b"}})
+}
+
+func TestScheduledWorkWaitsForDependencies(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	gf := getDependentGopherd(func(w *Work) Status {
+		mu.Lock()
+		order = append(order, w.ID)
+		mu.Unlock()
+		return Success
+	})
+	gf.Start()
+	gf.SendWorkAfter(Work{ID: "b", DependsOn: []string{"a"}}, time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for info, _ := gf.WorkInfo("b"); info.State != StateWaiting; info, _ = gf.WorkInfo("b") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected b to wait for a once due, got: %s", info.State)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	gf.SendWork(Work{ID: "a"})
+	gf.CloseInputChan()
+
+	outputs := collectOutput(gf, t)
+	a, b := outputs["a"], outputs["b"]
+	if a.Status() != Success || b.Status() != Success {
+		t.Fatalf("expected both to succeed, got: %s and %s", a.Status(), b.Status())
+	}
+	if len(order) != 2 || order[0] != "a" {
+		t.Fatalf("expected a to be processed before b, got: %v", order)
+	}
+}
+
+func TestDependencyGraphForgetsOldCompletedWork(t *testing.T) {
+	dg := newDependencyGraph()
+	for i := 0; i < completedLimit+10; i++ {
+		dg.finished(strconv.Itoa(i), Success)
+	}
+	if len(dg.completed) != completedLimit || len(dg.order) != completedLimit {
+		t.Fatalf("expected %d completed work units to be remembered, got: %d", completedLimit, len(dg.completed))
+	}
+	if _, ok := dg.completed["0"]; ok {
+		t.Fatalf("expected the oldest completed work to be forgotten")
+	}
+	if _, ok := dg.completed[strconv.Itoa(completedLimit+9)]; !ok {
+		t.Fatalf("expected the latest completed work to be remembered")
+	}
+}
@@ -0,0 +1,137 @@
+package gofherd
+
+import (
+	"sync"
+	"time"
+)
+
+// OverflowPolicy decides what happens when the async hook queue is full.
+type OverflowPolicy int
+
+const (
+	// Block makes the gopher wait until there is room in the queue.
+	Block OverflowPolicy = iota
+	// Drop discards the new hook call.
+	Drop
+	// DropOldest discards the oldest queued hook call to make room for the new one.
+	DropOldest
+)
+
+// hookDispatcher runs hook calls on a small pool of workers, fed by a bounded queue.
+type hookDispatcher struct {
+	mu      sync.RWMutex
+	queue   chan func()
+	policy  OverflowPolicy
+	closed  bool
+	wg      sync.WaitGroup
+	metrics *metrics
+}
+
+func newHookDispatcher(workers, buffer int, policy OverflowPolicy, m *metrics) *hookDispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	if buffer < 0 {
+		buffer = 0
+	}
+	if buffer == 0 && policy == DropOldest {
+		// without a queue there is no oldest call to discard
+		policy = Drop
+	}
+	hd := &hookDispatcher{queue: make(chan func(), buffer), policy: policy, metrics: m}
+	hd.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go hd.work()
+	}
+	return hd
+}
+
+func (hd *hookDispatcher) work() {
+	defer hd.wg.Done()
+	for call := range hd.queue {
+		call()
+	}
+}
+
+// dispatch queues the call according to the overflow policy. Calls after close are dropped.
+func (hd *hookDispatcher) dispatch(call func()) {
+	hd.mu.RLock()
+	defer hd.mu.RUnlock()
+	if hd.closed {
+		hd.metrics.incrementCallbackDropped()
+		return
+	}
+	switch hd.policy {
+	case Drop:
+		select {
+		case hd.queue <- call:
+		default:
+			hd.metrics.incrementCallbackDropped()
+		}
+	case DropOldest:
+		for {
+			select {
+			case hd.queue <- call:
+				return
+			default:
+			}
+			select {
+			case <-hd.queue:
+				hd.metrics.incrementCallbackDropped()
+			default:
+			}
+		}
+	default:
+		hd.queue <- call
+	}
+}
+
+// close stops accepting calls and waits for the queued ones to run.
+func (hd *hookDispatcher) close() {
+	hd.mu.Lock()
+	if hd.closed {
+		hd.mu.Unlock()
+		return
+	}
+	hd.closed = true
+	close(hd.queue)
+	hd.mu.Unlock()
+	hd.wg.Wait()
+}
+
+// SetAsyncHooks makes hooks and callbacks run on a pool of `workers` goroutines instead of
+// the gopher, fed by a queue of `buffer` calls, one per lifecycle event. When the queue is full, the overflow policy
+// decides whether the gopher blocks or a call is dropped, DropOldest acts like Drop without a buffer.
+// Hooks receive a copy of the Work, and with more than one worker they may run out of order.
+// Queued calls are run before the output chan is closed. It must be called before Start.
+func (gf *Gofherd) SetAsyncHooks(workers, buffer int, policy OverflowPolicy) {
+	gf.dispatcher = newHookDispatcher(workers, buffer, policy, gf.metrics)
+}
+
+// runHooks calls `call` with every hook, on the calling goroutine or on the dispatcher.
+func (gf *Gofherd) runHooks(work *Work, call func(EventHook, *Work)) {
+	gf.hooksMu.RLock()
+	hooks := gf.hooks
+	gf.hooksMu.RUnlock()
+	if len(hooks) == 0 {
+		return
+	}
+	if gf.dispatcher == nil {
+		gf.invokeHooks(hooks, work, call)
+		return
+	}
+	var copied *Work
+	if work != nil {
+		w := *work
+		copied = &w
+	}
+	gf.dispatcher.dispatch(func() { gf.invokeHooks(hooks, copied, call) })
+}
+
+func (gf *Gofherd) invokeHooks(hooks []EventHook, work *Work, call func(EventHook, *Work)) {
+	for _, h := range hooks {
+		start := time.Now()
+		call(h, work)
+		gf.metrics.observeCallbackLatency(time.Since(start))
+	}
+}
@@ -0,0 +1,134 @@
+package gofherd
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHookDispatcherRunsQueuedCallsOnClose(t *testing.T) {
+	hd := newHookDispatcher(2, 10, Block, defaultMetrics)
+	calls := int64(0)
+	for i := 0; i < 10; i++ {
+		hd.dispatch(func() { atomic.AddInt64(&calls, 1) })
+	}
+	hd.close()
+	if calls != 10 {
+		t.Fatalf("expected all queued calls to run before close returns, got: %d", calls)
+	}
+}
+
+func TestHookDispatcherDrop(t *testing.T) {
+	release := make(chan struct{})
+	m := newMetrics(prometheus.NewRegistry())
+	hd := newHookDispatcher(1, 1, Drop, m)
+	started := make(chan struct{})
+	hd.dispatch(func() { close(started); <-release })
+	<-started
+
+	ran := int64(0)
+	for i := 0; i < 3; i++ {
+		hd.dispatch(func() { atomic.AddInt64(&ran, 1) })
+	}
+	if dropped := testutil.ToFloat64(m.callbackDropped); dropped != 2 {
+		t.Fatalf("expected 2 dropped calls, got: %f", dropped)
+	}
+	close(release)
+	hd.close()
+	if ran != 1 {
+		t.Fatalf("expected 1 call to run, got: %d", ran)
+	}
+}
+
+func TestHookDispatcherDropOldest(t *testing.T) {
+	release := make(chan struct{})
+	hd := newHookDispatcher(1, 2, DropOldest, defaultMetrics)
+	started := make(chan struct{})
+	hd.dispatch(func() { close(started); <-release })
+	<-started
+
+	var mu sync.Mutex
+	var ran []int
+	for i := 0; i < 4; i++ {
+		i := i
+		hd.dispatch(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			ran = append(ran, i)
+		})
+	}
+	close(release)
+	hd.close()
+	if len(ran) != 2 || ran[0] != 2 || ran[1] != 3 {
+		t.Fatalf("expected only the 2 newest calls to run, got: %v", ran)
+	}
+}
+
+func TestHookDispatcherDropOldestWithoutBuffer(t *testing.T) {
+	release := make(chan struct{})
+	m := newMetrics(prometheus.NewRegistry())
+	hd := newHookDispatcher(1, 0, DropOldest, m)
+	if hd.policy != Drop {
+		t.Fatalf("expected DropOldest without a buffer to fall back to Drop, got: %d", hd.policy)
+	}
+	// the worker takes the first call once it is waiting on the queue
+	started := make(chan struct{})
+	var once sync.Once
+	for running := false; !running; {
+		hd.dispatch(func() { once.Do(func() { close(started) }); <-release })
+		select {
+		case <-started:
+			running = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	before := testutil.ToFloat64(m.callbackDropped)
+
+	done := make(chan struct{})
+	go func() {
+		hd.dispatch(func() {})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the call to be dropped without blocking")
+	}
+	if dropped := testutil.ToFloat64(m.callbackDropped) - before; dropped != 1 {
+		t.Fatalf("expected 1 dropped call, got: %f", dropped)
+	}
+	close(release)
+	hd.close()
+}
+
+func TestGopherdAsyncHooksDoNotBlockGophers(t *testing.T) {
+	workUnits := 5
+	gf := getBasicGopherd(0, workUnits, 1, Success)
+	gf.SetAsyncHooks(1, 3*workUnits, Block)
+	release := make(chan struct{})
+	calls := int64(0)
+	gf.AddSuccessCallback(func(w *Work) {
+		<-release
+		atomic.AddInt64(&calls, 1)
+	})
+	gf.Start()
+
+	for i := 0; i < workUnits; i++ {
+		select {
+		case <-gf.OutputChan():
+		case <-time.After(2 * time.Second):
+			t.Fatalf("slow callback blocked the gopher")
+		}
+	}
+	close(release)
+	for range gf.OutputChan() {
+	}
+	if atomic.LoadInt64(&calls) != int64(workUnits) {
+		t.Fatalf("expected callbacks to complete before the output chan is closed, got: %d", calls)
+	}
+	assertAllChannelsClosed(gf, t)
+}
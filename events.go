@@ -0,0 +1,110 @@
+package gofherd
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// eventBufferSize is the number of events buffered per subscriber before events are dropped.
+const eventBufferSize = 256
+
+// EventType is the kind of lifecycle event.
+type EventType string
+
+const (
+	// EventEnqueue is published when Work is sent with SendWork.
+	EventEnqueue EventType = "enqueue"
+	// EventStart is published when an attempt of the processing logic starts.
+	EventStart EventType = "start"
+	// EventRetry is published when Work is pushed to the retry path.
+	EventRetry EventType = "retry"
+	// EventSuccess is published when Work reaches the Success status.
+	EventSuccess EventType = "success"
+	// EventFailure is published when Work reaches the Failure status.
+	EventFailure EventType = "failure"
+	// EventCancelled is published when Work reaches the Cancelled status.
+	EventCancelled EventType = "cancelled"
+	// EventHerdResize is published when the herd size changes.
+	EventHerdResize EventType = "herd_resize"
+)
+
+var statusEvents = map[Status]EventType{
+	Success:   EventSuccess,
+	Failure:   EventFailure,
+	Cancelled: EventCancelled,
+}
+
+// Event is a lifecycle event of a Work unit or of the herd.
+type Event struct {
+	Type     EventType `json:"type"`
+	Time     time.Time `json:"time"`
+	WorkID   string    `json:"work_id,omitempty"`
+	GopherID int64     `json:"gopher_id,omitempty"`
+	Attempt  int64     `json:"attempt,omitempty"`
+	HerdSize int64     `json:"herd_size,omitempty"`
+}
+
+type subscriber struct {
+	events  chan Event
+	types   map[EventType]bool
+	dropped uint64
+}
+
+func (s *subscriber) wants(t EventType) bool {
+	return len(s.types) == 0 || s.types[t]
+}
+
+func (s *subscriber) droppedCount() uint64 {
+	return atomic.LoadUint64(&(s.dropped))
+}
+
+// eventBus fans out events to subscribers without ever blocking the publisher.
+// Events which do not fit in a subscriber's buffer are dropped and counted.
+type eventBus struct {
+	mu          sync.RWMutex
+	subscribers map[*subscriber]struct{}
+	now         func() time.Time
+	metrics     *metrics
+}
+
+func newEventBus(m *metrics) *eventBus {
+	return &eventBus{subscribers: make(map[*subscriber]struct{}), now: time.Now, metrics: m}
+}
+
+func (eb *eventBus) subscribe(types ...EventType) *subscriber {
+	s := &subscriber{events: make(chan Event, eventBufferSize), types: make(map[EventType]bool)}
+	for _, t := range types {
+		s.types[t] = true
+	}
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.subscribers[s] = struct{}{}
+	return s
+}
+
+func (eb *eventBus) unsubscribe(s *subscriber) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	delete(eb.subscribers, s)
+}
+
+func (eb *eventBus) publish(event Event) {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+	if len(eb.subscribers) == 0 {
+		return
+	}
+	event.Time = eb.now()
+	for s := range eb.subscribers {
+		if !s.wants(event.Type) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			atomic.AddUint64(&(s.dropped), 1)
+			eb.metrics.incrementEventsDropped()
+		}
+	}
+}
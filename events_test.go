@@ -0,0 +1,81 @@
+package gofherd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEventBusFiltersByType(t *testing.T) {
+	eb := newEventBus(defaultMetrics)
+	all := eb.subscribe()
+	failures := eb.subscribe(EventFailure)
+
+	eb.publish(Event{Type: EventSuccess, WorkID: "a"})
+	eb.publish(Event{Type: EventFailure, WorkID: "b"})
+
+	if len(all.events) != 2 || len(failures.events) != 1 {
+		t.Fatalf("did not get expected events, all: %d, failures: %d", len(all.events), len(failures.events))
+	}
+	if e := <-failures.events; e.WorkID != "b" {
+		t.Fatalf("did not get expected event, got: %+v", e)
+	}
+
+	eb.unsubscribe(all)
+	eb.publish(Event{Type: EventSuccess, WorkID: "c"})
+	if len(all.events) != 2 {
+		t.Fatalf("expected no events after unsubscribe, got: %d", len(all.events))
+	}
+}
+
+func TestEventBusDropsForSlowSubscribers(t *testing.T) {
+	eb := newEventBus(defaultMetrics)
+	s := eb.subscribe()
+	for i := 0; i < eventBufferSize+10; i++ {
+		eb.publish(Event{Type: EventEnqueue})
+	}
+	if len(s.events) != eventBufferSize || s.droppedCount() != 10 {
+		t.Fatalf("expected %d buffered and 10 dropped, got: %d buffered and %d dropped", eventBufferSize, len(s.events), s.droppedCount())
+	}
+}
+
+func TestEventsHandlerStreamsNDJSON(t *testing.T) {
+	workUnits := 3
+	gf := New(func(w *Work) Status { return Success })
+	gf.SetHerdSize(1)
+	gf.DisableServer()
+	server := httptest.NewServer(gf.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events?format=ndjson&type=enqueue,success")
+	if err != nil {
+		t.Fatalf("could not get events: %s", err)
+	}
+	defer resp.Body.Close()
+
+	go func() {
+		for i := 0; i < workUnits; i++ {
+			gf.SendWork(Work{ID: fmt.Sprintf("%d", i)})
+		}
+		gf.CloseInputChan()
+	}()
+	gf.Start()
+	for range gf.OutputChan() {
+	}
+
+	counts := map[EventType]int{}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("could not decode event %q: %s", scanner.Text(), err)
+		}
+		counts[event.Type]++
+	}
+	if len(counts) != 2 || counts[EventEnqueue] != workUnits || counts[EventSuccess] != workUnits {
+		t.Fatalf("did not get expected events, got: %v", counts)
+	}
+}
@@ -1,9 +1,8 @@
 package main
 
 import (
-	"encoding/csv"
+	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"time"
@@ -16,30 +15,22 @@ func LoadWork(herd *gf.Gofherd) {
 	if err != nil {
 		panic("could not find sites.csv. Please run from inside examples dir")
 	}
-	sitesReader := csv.NewReader(sites)
-	counter := 0
-	for {
-		site, err := sitesReader.Read()
-		if err == io.EOF {
-			break
-		}
-		w := gf.Work{ID: fmt.Sprintf("%d", counter), Body: site[0]}
-		herd.SendWork(w)
-		counter++
+	defer sites.Close()
+	if _, err := herd.Consume(context.Background(), gf.NewLinesSource(sites)); err != nil {
+		panic(err)
 	}
-	herd.CloseInputChan()
 }
 
-func ProcessWork(w *gf.Work) gf.Status {
-	start := time.Now()
-	http.Get(w.Body.(string))
-	w.SetResult(time.Since(start))
-	return gf.Success
-}
+// ProcessWork gets the site in the Work Body, retrying on 429 and 5xx responses
+var ProcessWork = gf.NewHTTPProcessor(gf.HTTPOptions{
+	Client:      &http.Client{Timeout: 10 * time.Second},
+	MaxBodySize: 1024,
+}).Process
 
 func ReviewOutput(outputChan <-chan gf.Work) {
 	for work := range outputChan {
-		fmt.Printf("site: %s, latency: %s\n", work.Body.(string), work.Result())
+		result := work.Result().(gf.HTTPResult)
+		fmt.Printf("site: %s, status: %s, code: %d, latency: %s\n", work.Body.(string), work.Status(), result.StatusCode, result.Latency)
 	}
 }
 
@@ -48,12 +39,15 @@ func SuccessCallback(work *gf.Work) {
 }
 
 func main() {
-	herd := gf.New(ProcessWork)
+	herd := gf.NewWithContext(ProcessWork)
 	herd.SetHerdSize(5)
 	herd.SetMaxRetries(100)
+	herd.SetRetryBackoff(time.Second)
 	herd.SetAddr("127.0.0.1:5555")
 	herd.AddSuccessCallback(SuccessCallback)
 	go LoadWork(herd)
-	herd.Start()
+	if err := herd.Start(); err != nil {
+		panic(err)
+	}
 	ReviewOutput(herd.OutputChan())
 }
@@ -0,0 +1,247 @@
+package gofherd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// execOutputLimit is the default number of bytes of stdout and stderr kept in ExecResult.
+const execOutputLimit = 64 * 1024
+
+// errProcessorClosed is the ExecResult error of Work processed after Close.
+var errProcessorClosed = errors.New("exec processor closed")
+
+// ExecOptions configures the processing logic of NewExecProcessor.
+type ExecOptions struct {
+	// Args is the program and its arguments. Each one is a text/template executed with
+	// the Work, so `{{.ID}}` and `{{.Body}}` can be used, or `{{.Body.path}}` for a map Body.
+	Args []string
+	// Stdin is a template like Args, whose output is written to the stdin of the program.
+	// An empty Stdin leaves stdin empty.
+	Stdin string
+	// Dir is the working directory of the program, it defaults to the current one.
+	Dir string
+	// Env is the environment of the program, it defaults to the environment of the process.
+	Env []string
+	// SuccessCodes are the exit codes treated as Success, it defaults to 0.
+	SuccessCodes []int
+	// RetryCodes are the exit codes treated as Retry. Any other exit code is a Failure.
+	RetryCodes []int
+	// OutputLimit is the number of bytes of stdout and stderr kept, it defaults to 64KB.
+	OutputLimit int
+	// Timeout kills the program if it runs longer, and the attempt is retried.
+	// 0 only relies on SetWorkTimeout.
+	Timeout time.Duration
+}
+
+// ExecResult is set as the Result of Work processed by an ExecProcessor.
+type ExecResult struct {
+	Args     []string      `json:"args"`
+	ExitCode int           `json:"exit_code"`
+	Stdout   string        `json:"stdout"`
+	Stderr   string        `json:"stderr"`
+	Duration time.Duration `json:"duration"`
+	// Error is set when the program could not be run, or was killed.
+	Error string `json:"error,omitempty"`
+}
+
+// ExecProcessor is processing logic running a program per Work unit. Pass its Process
+// method to NewWithContext. When the context of an attempt is done, on a timeout or
+// Cancel, the whole process group of the program is killed.
+type ExecProcessor struct {
+	opts         ExecOptions
+	args         []*template.Template
+	stdin        *template.Template
+	successCodes map[int]bool
+	retryCodes   map[int]bool
+	ctx          context.Context
+	cancel       context.CancelFunc
+	mu           sync.Mutex
+	closed       bool
+	wg           sync.WaitGroup
+}
+
+// NewExecProcessor returns an ExecProcessor, or an error if the templates cannot be parsed.
+func NewExecProcessor(opts ExecOptions) (*ExecProcessor, error) {
+	if len(opts.Args) == 0 {
+		return nil, errors.New("exec processor: no program in Args")
+	}
+	if opts.OutputLimit <= 0 {
+		opts.OutputLimit = execOutputLimit
+	}
+	if len(opts.SuccessCodes) == 0 {
+		opts.SuccessCodes = []int{0}
+	}
+	ep := &ExecProcessor{opts: opts, successCodes: codeSet(opts.SuccessCodes), retryCodes: codeSet(opts.RetryCodes)}
+	for i, arg := range opts.Args {
+		tmpl, err := template.New(fmt.Sprintf("arg%d", i)).Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("exec processor: %w", err)
+		}
+		ep.args = append(ep.args, tmpl)
+	}
+	if opts.Stdin != "" {
+		tmpl, err := template.New("stdin").Option("missingkey=error").Parse(opts.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("exec processor: %w", err)
+		}
+		ep.stdin = tmpl
+	}
+	ep.ctx, ep.cancel = context.WithCancel(context.Background())
+	return ep, nil
+}
+
+func codeSet(codes []int) map[int]bool {
+	set := make(map[int]bool, len(codes))
+	for _, code := range codes {
+		set[code] = true
+	}
+	return set
+}
+
+// execData is what the templates are executed with.
+type execData struct {
+	ID   string
+	Body interface{}
+}
+
+func execute(tmpl *template.Template, w *Work) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, execData{ID: w.ID, Body: w.Body}); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func (ep *ExecProcessor) status(exitCode int) Status {
+	switch {
+	case ep.successCodes[exitCode]:
+		return Success
+	case ep.retryCodes[exitCode]:
+		return Retry
+	default:
+		return Failure
+	}
+}
+
+// Process runs the program for the Work and sets an ExecResult as its Result.
+// Work whose templates cannot be executed is a Failure.
+func (ep *ExecProcessor) Process(ctx context.Context, w *Work) Status {
+	result := ExecResult{ExitCode: -1}
+	defer func() { w.SetResult(result) }()
+	ep.mu.Lock()
+	if ep.closed {
+		ep.mu.Unlock()
+		result.Error = errProcessorClosed.Error()
+		return Failure
+	}
+	ep.wg.Add(1)
+	ep.mu.Unlock()
+	defer ep.wg.Done()
+
+	for _, tmpl := range ep.args {
+		arg, err := execute(tmpl, w)
+		if err != nil {
+			result.Error = err.Error()
+			return Failure
+		}
+		result.Args = append(result.Args, arg)
+	}
+	var stdin string
+	if ep.stdin != nil {
+		var err error
+		if stdin, err = execute(ep.stdin, w); err != nil {
+			result.Error = err.Error()
+			return Failure
+		}
+	}
+
+	if ep.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ep.opts.Timeout)
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(ep.ctx, cancel)
+	defer stop()
+
+	cmd := exec.CommandContext(ctx, result.Args[0], result.Args[1:]...)
+	cmd.Dir = ep.opts.Dir
+	cmd.Env = ep.opts.Env
+	cmd.Stdin = strings.NewReader(stdin)
+	stdout, stderr := &limitedBuffer{limit: ep.opts.OutputLimit}, &limitedBuffer{limit: ep.opts.OutputLimit}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	// do not wait forever for children which inherited stdout or stderr
+	cmd.WaitDelay = time.Second
+	setProcessGroup(cmd)
+
+	start := time.Now()
+	err := cmd.Run()
+	result.Duration = time.Since(start)
+	result.Stdout, result.Stderr = stdout.String(), stderr.String()
+
+	if ep.ctx.Err() != nil {
+		result.Error = errProcessorClosed.Error()
+		return Failure
+	}
+	if ctx.Err() != nil {
+		result.Error = ctx.Err().Error()
+		return Retry
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+		return ep.status(result.ExitCode)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return Failure
+	}
+	result.ExitCode = 0
+	return ep.status(0)
+}
+
+// Close kills the programs still running, and waits for their attempts to finish.
+// Work processed after Close is a Failure without running the program.
+// Call it when the herd is shut down early, so that no programs are left behind.
+func (ep *ExecProcessor) Close() {
+	ep.mu.Lock()
+	ep.closed = true
+	ep.cancel()
+	ep.mu.Unlock()
+	ep.wg.Wait()
+}
+
+// limitedBuffer keeps the first `limit` bytes written to it and discards the rest.
+type limitedBuffer struct {
+	mu    sync.Mutex
+	limit int
+	buf   bytes.Buffer
+}
+
+func (lb *limitedBuffer) Write(p []byte) (int, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if room := lb.limit - lb.buf.Len(); room > 0 {
+		if len(p) > room {
+			lb.buf.Write(p[:room])
+		} else {
+			lb.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (lb *limitedBuffer) String() string {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.buf.String()
+}
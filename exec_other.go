@@ -0,0 +1,9 @@
+//go:build !unix
+
+package gofherd
+
+import "os/exec"
+
+// setProcessGroup leaves the program as is, cancelling it only kills the program itself.
+func setProcessGroup(cmd *exec.Cmd) {
+}
@@ -0,0 +1,119 @@
+package gofherd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestExecProcessor(opts ExecOptions, t *testing.T) *ExecProcessor {
+	ep, err := NewExecProcessor(opts)
+	if err != nil {
+		t.Fatalf("could not create exec processor: %s", err)
+	}
+	return ep
+}
+
+func TestExecProcessorTemplates(t *testing.T) {
+	ep := newTestExecProcessor(ExecOptions{Args: []string{"sh", "-c", "echo {{.ID}} {{.Body.name}}; cat; echo oops >&2"}, Stdin: "in:{{.Body.name}}"}, t)
+	work := Work{ID: "a", Body: map[string]string{"name": "gopher"}}
+	if status := ep.Process(context.Background(), &work); status != Success {
+		t.Fatalf("expected success, got: %s, result: %+v", status, work.Result())
+	}
+	result := work.Result().(ExecResult)
+	if result.Stdout != "a gopher\nin:gopher" || result.Stderr != "oops\n" || result.ExitCode != 0 || result.Args[2] != "echo a gopher; cat; echo oops >&2" {
+		t.Fatalf("did not get expected result, got: %+v", result)
+	}
+}
+
+func TestExecProcessorExitCodes(t *testing.T) {
+	ep := newTestExecProcessor(ExecOptions{Args: []string{"sh", "-c", "exit {{.Body}}"}, SuccessCodes: []int{0, 1}, RetryCodes: []int{75}}, t)
+	for code, expected := range map[string]Status{"0": Success, "1": Success, "75": Retry, "2": Failure} {
+		work := Work{ID: code, Body: code}
+		if status := ep.Process(context.Background(), &work); status != expected {
+			t.Fatalf("expected %s for exit code %s, got: %s", expected, code, status)
+		}
+	}
+}
+
+func TestExecProcessorErrors(t *testing.T) {
+	if _, err := NewExecProcessor(ExecOptions{}); err == nil {
+		t.Fatalf("expected an error without a program")
+	}
+	if _, err := NewExecProcessor(ExecOptions{Args: []string{"echo", "{{.ID"}}); err == nil {
+		t.Fatalf("expected an error for an invalid template")
+	}
+	ep := newTestExecProcessor(ExecOptions{Args: []string{"echo", "{{.Body.missing}}"}}, t)
+	work := Work{ID: "a", Body: map[string]string{}}
+	if status := ep.Process(context.Background(), &work); status != Failure || work.Result().(ExecResult).Error == "" {
+		t.Fatalf("expected failure for a missing key, got: %s, %+v", status, work.Result())
+	}
+	ep = newTestExecProcessor(ExecOptions{Args: []string{"/does/not/exist"}}, t)
+	if status := ep.Process(context.Background(), &work); status != Failure {
+		t.Fatalf("expected failure for a missing program, got: %s", status)
+	}
+}
+
+func TestExecProcessorOutputLimit(t *testing.T) {
+	ep := newTestExecProcessor(ExecOptions{Args: []string{"sh", "-c", "yes | head -c 1000"}, OutputLimit: 10}, t)
+	work := Work{ID: "a"}
+	ep.Process(context.Background(), &work)
+	if stdout := work.Result().(ExecResult).Stdout; stdout != strings.Repeat("y\n", 5) {
+		t.Fatalf("expected stdout to be capped at 10 bytes, got: %q", stdout)
+	}
+}
+
+func TestExecProcessorTimeout(t *testing.T) {
+	ep := newTestExecProcessor(ExecOptions{Args: []string{"sleep", "10"}, Timeout: 50 * time.Millisecond}, t)
+	work := Work{ID: "a"}
+	start := time.Now()
+	if status := ep.Process(context.Background(), &work); status != Retry {
+		t.Fatalf("expected retry on timeout, got: %s", status)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the program to be killed on timeout, took: %s", elapsed)
+	}
+}
+
+func TestExecProcessorClose(t *testing.T) {
+	ep := newTestExecProcessor(ExecOptions{Args: []string{"sleep", "10"}}, t)
+	done := make(chan Status)
+	go func() {
+		work := Work{ID: "a"}
+		done <- ep.Process(context.Background(), &work)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	go ep.Close()
+	select {
+	case status := <-done:
+		if status != Failure {
+			t.Fatalf("expected failure after close, got: %s", status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected close to kill the running program")
+	}
+	work := Work{ID: "b"}
+	if status := ep.Process(context.Background(), &work); status != Failure || work.Result().(ExecResult).Error != errProcessorClosed.Error() {
+		t.Fatalf("expected failure without running after close, got: %s, %+v", status, work.Result())
+	}
+}
+
+func TestExecProcessorInHerd(t *testing.T) {
+	ep := newTestExecProcessor(ExecOptions{Args: []string{"sh", "-c", "exit {{.Body}}"}, RetryCodes: []int{75}}, t)
+	gf := NewWithContext(ep.Process)
+	gf.SetHerdSize(2)
+	gf.SetMaxRetries(1)
+	gf.SetAddr("127.0.0.1:0")
+	go func() {
+		gf.SendWork(Work{ID: "ok", Body: 0})
+		gf.SendWork(Work{ID: "retry", Body: 75})
+		gf.CloseInputChan()
+	}()
+	gf.Start()
+	for work := range gf.OutputChan() {
+		if work.ID == "ok" && work.Status() != Success || work.ID == "retry" && (work.Status() != Failure || work.Retries() != 1) {
+			t.Fatalf("did not get expected status for %s, got: %s after %d retries", work.ID, work.Status(), work.Retries())
+		}
+	}
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package gofherd
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the program in a process group of its own, and makes
+// cancelling it kill the whole group, including any children it started.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,41 @@
+//go:build unix
+
+package gofherd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExecProcessorKillsProcessGroup(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	ep, err := NewExecProcessor(ExecOptions{Args: []string{"sh", "-c", "sleep 30 & echo $! > {{.Body}}; wait"}})
+	if err != nil {
+		t.Fatalf("could not create exec processor: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	work := Work{ID: "a", Body: pidFile}
+	if status := ep.Process(ctx, &work); status != Retry {
+		t.Fatalf("expected retry when the context is done, got: %s", status)
+	}
+
+	content, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("could not read the pid of the child: %s", err)
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(content)))
+	deadline := time.Now().Add(5 * time.Second)
+	for syscall.Kill(pid, 0) == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected child %d to be killed with the process group", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -1,10 +1,14 @@
 package gofherd
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
-
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Gofherd is the core struct, orchestrating all functionality.
@@ -13,56 +17,206 @@ type Gofherd struct {
 	input           queue
 	output          queue
 	retry           queue
-	quit            chan struct{}
-	processingLogic func(*Work) Status
-	successCallback func(*Work)
-	retryCallback   func(*Work)
-	failureCallback func(*Work)
+	ordered         *reorderBuffer
+	deps            *dependencyGraph
+	progress        *progressTracker
+	registry        *workRegistry
+	failures        *failureLog
+	events          *eventBus
+	gophers         *gopherTracker
+	done            chan struct{}
+	processingLogic func(context.Context, *Work) Status
+	hooks           []EventHook
+	hooksMu         sync.RWMutex
+	dispatcher      *hookDispatcher
 	herdSize        int64
+	herdMu          sync.Mutex
+	started         bool
 	maxRetries      int64
+	retryBackoff    int64
+	workTimeout     int64
+	limiter         *rateLimiter
+	scheduler       *scheduler
+	recurring       *recurring
+	clock           Clock
+	metrics         *metrics
+	configMu        sync.Mutex
 	addr            string
-	logger          Logger
+	serverDisabled  bool
+	authToken       string
+	tlsConfig       *tls.Config
+	routes          []route
+	log             leveledLogger
+	tracer          Tracer
+	gopherSeq       int64
+	progressEvery   time.Duration
 }
 
 // New initializes a new Gofherd struct. It takes in the processing logic function
 // with the signature `func(*gf.Work) gf.Status`
 func New(processingLogic func(*Work) Status) *Gofherd {
+	return NewWithContext(func(ctx context.Context, w *Work) Status {
+		return processingLogic(w)
+	})
+}
+
+// NewWithContext initializes a new Gofherd struct with a context aware processing logic function,
+// with the signature `func(context.Context, *gf.Work) gf.Status`. The context carries the trace
+// context of the attempt and is cancelled when the Work unit is cancelled with Cancel.
+func NewWithContext(processingLogic func(context.Context, *Work) Status) *Gofherd {
+	// each herd has its own copy, so that SetMetricsRegistry does not affect other herds
+	m := *defaultMetrics
 	return &Gofherd{
 		processingLogic: processingLogic,
 		input:           newQueue(),
 		output:          newQueue(),
 		retry:           newQueue(),
 		addr:            "127.0.0.1:2112",
-		logger:          noOpLogger{},
-		quit:            make(chan struct{}),
+		log:             leveledLogger{logger: NewPrintfLogger(noOpLogger{}), min: LevelInfo},
+		tracer:          noOpTracer{},
+		progress:        newProgressTracker(),
+		registry:        newWorkRegistry(),
+		failures:        newFailureLog(),
+		events:          newEventBus(&m),
+		gophers:         newGopherTracker(&m),
+		limiter:         newRateLimiter(realClock{}),
+		scheduler:       newScheduler(realClock{}, &m),
+		recurring:       newRecurring(realClock{}),
+		clock:           realClock{},
+		metrics:         &m,
+		done:            make(chan struct{}),
 	}
 }
 
 // SetLogger is used to setup logging. If not specified, gofherd emits no logs.
+// Entries are formatted as `level msg key=value ...`, see NewPrintfLogger.
 func (gf *Gofherd) SetLogger(l Logger) {
-	gf.logger = l
+	gf.log.logger = NewPrintfLogger(l)
+}
+
+// SetStructuredLogger is used to setup structured logging, for example with NewSlogLogger.
+// It replaces the logger set with SetLogger.
+func (gf *Gofherd) SetStructuredLogger(l StructuredLogger) {
+	gf.log.logger = l
+}
+
+// SetLogLevel sets the minimum level of the entries logged. It defaults to LevelInfo,
+// use LevelDebug to log every enqueue and dequeue of Work.
+func (gf *Gofherd) SetLogLevel(level Level) {
+	gf.log.min = level
 }
 
-// AddSuccessCallback is used to setup logging. If not specified, gofherd emits no logs.
+// SetTracer is used to setup tracing. If not specified, gofherd creates no spans.
+func (gf *Gofherd) SetTracer(t Tracer) {
+	gf.tracer = t
+}
+
+// AddHook registers an EventHook. Any number of hooks can be added, see EventHook
+// for which goroutine they are called on. Hooks added while Work is being processed
+// are only called for events after they are added.
+func (gf *Gofherd) AddHook(h EventHook) {
+	gf.hooksMu.Lock()
+	defer gf.hooksMu.Unlock()
+	gf.hooks = append(gf.hooks, h)
+}
+
+// AddSuccessCallback registers a callback called when Work reaches the Success status.
+// Any number of callbacks can be added, they are called like an EventHook.
 func (gf *Gofherd) AddSuccessCallback(f func(*Work)) {
-	gf.successCallback = f
+	gf.AddHook(callbackHook{success: f})
 }
 
-// AddRetryCallback is used to setup logging. If not specified, gofherd emits no logs.
+// AddRetryCallback registers a callback called when Work is pushed to the retry path.
+// Any number of callbacks can be added, they are called like an EventHook.
 func (gf *Gofherd) AddRetryCallback(f func(*Work)) {
-	gf.retryCallback = f
+	gf.AddHook(callbackHook{retry: f})
 }
 
-// AddFailureCallback is used to setup logging. If not specified, gofherd emits no logs.
+// AddFailureCallback registers a callback called when Work reaches the Failure status.
+// Any number of callbacks can be added, they are called like an EventHook.
 func (gf *Gofherd) AddFailureCallback(f func(*Work)) {
-	gf.failureCallback = f
+	gf.AddHook(callbackHook{failure: f})
+}
+
+// AddCancelledCallback registers a callback called when Work reaches the Cancelled status.
+// Any number of callbacks can be added, they are called like an EventHook.
+func (gf *Gofherd) AddCancelledCallback(f func(*Work)) {
+	gf.AddHook(callbackHook{cancelled: f})
+}
+
+// SetOrderedOutput makes OutputChan emit Work in the order it was sent with SendWork.
+// Completed Work is held back until all Work sent before it has been emitted.
+// The window bounds how many units can be in flight or held back at once,
+// SendWork blocks when it is full. A window of 0 is unbounded. Work sent with SendWorkAt,
+// SendWorkAfter or a schedule takes its place in the order when it is due, so it does not block.
+// It must be called before sending any Work.
+func (gf *Gofherd) SetOrderedOutput(window int64) {
+	gf.ordered = newReorderBuffer(window, gf.metrics)
 }
 
 // SendWork enques Work onto the input chan.
 func (gf *Gofherd) SendWork(work Work) {
+	gf.SendWorkContext(context.Background(), work)
+}
+
+// SendWorkContext enques Work onto the input chan. The span of the Work unit
+// is created as a child of the trace context in ctx.
+func (gf *Gofherd) SendWorkContext(ctx context.Context, work Work) {
+	gf.sendWork(ctx, work, nil)
+}
+
+// sendWork enques Work onto the input chan. If stop is closed before a gopher picks
+// the Work up, it is emitted as Cancelled instead, and sendWork returns false.
+func (gf *Gofherd) sendWork(ctx context.Context, work Work, stop <-chan struct{}) bool {
+	if len(work.DependsOn) > 0 {
+		gf.sendDependent(ctx, work)
+		return true
+	}
+	gf.prepareWork(ctx, &work, StateQueued)
 	gf.input.increment()
-	gf.input.hose <- work
-	gf.logger.Printf("Pushed to input, work: %s\n", work.ID)
+	select {
+	case gf.input.hose <- work:
+	case <-stop:
+		gf.log.warn("cancelled work, sending was stopped", Field{"work_id", work.ID})
+		work.setStatus(Cancelled)
+		// the output chan may not be read until the sender returns
+		go gf.pushToOutputChan(work)
+		return false
+	}
+	gf.log.debug("pushed work to input", Field{"work_id", work.ID})
+	return true
+}
+
+// prepareWork starts the span of the Work unit and records that it was sent.
+func (gf *Gofherd) prepareWork(ctx context.Context, work *Work, state WorkState) {
+	work.ctx, work.span = gf.tracer.Start(ctx, "gofherd.work", Field{"work_id", work.ID})
+	switch state {
+	case StateScheduled:
+		gf.registry.scheduled(work.ID)
+	case StateWaiting:
+		gf.registry.waiting(work.ID)
+	default:
+		gf.registry.queued(work.ID)
+	}
+	gf.events.publish(Event{Type: EventEnqueue, WorkID: work.ID})
+	gf.runHooks(work, EventHook.OnEnqueue)
+	// scheduled Work takes its place in the order once it is due, see dueWork
+	if gf.ordered != nil && state != StateScheduled {
+		work.seq = gf.ordered.assign()
+	}
+}
+
+// dueWork records that scheduled Work is due, before it is handed to the gophers.
+// Work with dependencies is held until they succeed, and dueWork returns false.
+func (gf *Gofherd) dueWork(work *Work) bool {
+	if gf.ordered != nil {
+		work.seq = gf.ordered.assign()
+	}
+	if len(work.DependsOn) > 0 && !gf.dueDependent(work) {
+		return false
+	}
+	gf.registry.due(work.ID)
+	return true
 }
 
 // OutputChan returns the output chan, it will be closed when the processing is complete,
@@ -78,7 +232,7 @@ func (gf *Gofherd) CloseInputChan() {
 	defer gf.input.unlock()
 	if !gf.input.closed() {
 		close(gf.input.hose)
-		gf.logger.Printf("Closed input chan\n")
+		gf.log.info("closed input chan", Field{"submitted", gf.input.count()})
 		gf.input.setClosedTrue()
 		gf.maintainRetry()
 	}
@@ -88,16 +242,23 @@ func (gf *Gofherd) closeOutputChan() {
 	gf.output.lock()
 	defer gf.output.unlock()
 	if !gf.output.closed() {
+		if gf.dispatcher != nil {
+			gf.dispatcher.close()
+		}
 		close(gf.output.hose)
-		gf.logger.Printf("Closed output chan\n")
+		gf.log.info("closed output chan", Field{"completed", gf.output.count()})
 		gf.output.setClosedTrue()
+		close(gf.done)
 	}
 }
 
 // SetHerdSize sets the herd size. The passed number is the number of
-// gofhers spawned up for processing.
+// gofhers spawned up for processing. Once started, the herd is resized to it.
 func (gf *Gofherd) SetHerdSize(num int64) {
-	gf.herdSize = num
+	if num < 0 {
+		num = 0
+	}
+	gf.resize(func(int64) int64 { return num })
 }
 
 // SetAddr accepts the `addr` string where the started server will be spun up.
@@ -105,19 +266,144 @@ func (gf *Gofherd) SetAddr(addr string) {
 	gf.addr = addr
 }
 
+// DisableServer stops Start from spinning up the server on `addr`.
+// The handlers can still be mounted on another server using Handler.
+func (gf *Gofherd) DisableServer() {
+	gf.serverDisabled = true
+}
+
+// SetAuthToken makes the control handlers require an `Authorization: Bearer <token>` header.
+// Browsers open the dashboard at `/dashboard?token=<token>`, which stores the token in a cookie.
+func (gf *Gofherd) SetAuthToken(token string) {
+	gf.authToken = token
+}
+
+// SetTLSConfig makes the started server serve TLS with the passed config, which must
+// contain the server certificates. For mTLS, set `ClientAuth` to `tls.RequireAndVerifyClientCert`
+// and `ClientCAs` to the pool of trusted client certificate authorities.
+func (gf *Gofherd) SetTLSConfig(config *tls.Config) {
+	gf.tlsConfig = config
+}
+
 // SetMaxRetries is the maximum number of times a Work unit will be tried before giving up.
+// It is safe to call while the herd runs, see SetConfig to validate the value.
 func (gf *Gofherd) SetMaxRetries(num int64) {
-	gf.maxRetries = num
+	atomic.StoreInt64(&(gf.maxRetries), num)
+}
+
+// SetExpectedTotal is a hint of the total number of Work units which will be sent.
+// It is used to compute the ETA in Progress.
+func (gf *Gofherd) SetExpectedTotal(num uint64) {
+	gf.progress.setExpected(num)
+}
+
+// SetProgressInterval makes gofherd log a progress line through the Logger
+// every `interval` until processing is complete. It is disabled by default.
+func (gf *Gofherd) SetProgressInterval(interval time.Duration) {
+	gf.progressEvery = interval
+}
+
+// Progress returns a snapshot of the processing progress.
+func (gf *Gofherd) Progress() Progress {
+	return gf.progress.snapshot(gf.input.count())
+}
+
+// Gophers returns the busy and idle accounting of the running gophers.
+func (gf *Gofherd) Gophers() []GopherStats {
+	return gf.gophers.stats()
+}
+
+// Utilization returns the fraction of time the running gophers have spent processing Work.
+func (gf *Gofherd) Utilization() float64 {
+	return gf.gophers.utilizationNow()
+}
+
+// Cancel cancels the Work unit with the given ID. Queued, scheduled, waiting or retrying Work is not processed
+// again and in flight Work has its context cancelled, see NewWithContext. Either way,
+// the Work unit is pushed to the output chan with the Cancelled status once a gopher has it.
+// It returns ErrWorkNotFound for unknown IDs and ErrWorkDone if the Work is already done,
+// or has returned a final status and is about to be emitted with it.
+func (gf *Gofherd) Cancel(id string) error {
+	if err := gf.registry.cancel(id); err != nil {
+		return err
+	}
+	gf.scheduler.expedite(id)
+	if gf.deps != nil {
+		if work, ok := gf.deps.remove(id); ok {
+			gf.releaseDependent(work)
+		}
+	}
+	gf.log.info("cancelled work", Field{"work_id", id})
+	return nil
+}
+
+// InFlight returns the Work units currently inside the processing logic.
+func (gf *Gofherd) InFlight() []InFlightWork {
+	return gf.registry.inFlight()
+}
+
+// Retrying returns the Work units waiting in the retry path.
+func (gf *Gofherd) Retrying() []WorkInfo {
+	return gf.registry.inState(StateRetrying)
+}
+
+// WorkInfo returns the state and attempt history of the Work unit with the given ID.
+// The history of completed Work is kept for the last 10000 units, with up to 100 attempts each.
+// If several Work units share an ID, the last one sent is returned.
+func (gf *Gofherd) WorkInfo(id string) (WorkInfo, bool) {
+	return gf.registry.get(id)
+}
+
+func (gf *Gofherd) logProgress() {
+	ticker := time.NewTicker(gf.progressEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-gf.done:
+			return
+		case <-ticker.C:
+			p := gf.Progress()
+			gf.log.info("progress",
+				Field{"submitted", p.Submitted},
+				Field{"success", p.Success},
+				Field{"failure", p.Failure},
+				Field{"in_flight", p.InFlight},
+				Field{"pending_retries", p.PendingRetries},
+				Field{"throughput_1m", p.Throughput.Last1m},
+				Field{"eta", p.ETA})
+		}
+	}
 }
 
 func (gf *Gofherd) pushToOutputChan(work Work) {
+	gf.progress.completed(work.Status())
+	gf.registry.done(work.ID, work.Status())
+	gf.recurring.finished(work.ID)
+	gf.resolveDependents(&work)
+	gf.events.publish(Event{Type: statusEvents[work.Status()], WorkID: work.ID, Attempt: work.retryCount() + 1})
 	if work.Status() == Success {
 		gf.registerSuccess(&work)
 	}
 	if work.Status() == Failure {
 		gf.registerFailure(&work)
+		gf.failures.add(&work)
+	}
+	if work.Status() == Cancelled {
+		gf.registerCancelled(&work)
+	}
+	if gf.ordered != nil {
+		gf.ordered.push(work, gf.emit)
+		return
+	}
+	gf.emit(work)
+}
+
+func (gf *Gofherd) emit(work Work) {
+	gf.log.debug("pushing work to output", workFields(&work)...)
+	if work.span != nil {
+		work.span.SetAttributes(Field{"retries", work.retryCount()}, Field{"status", work.Status().String()})
+		work.span.End()
 	}
-	gf.logger.Printf("Pusing to output, work: %s\n", work.ID)
 	gf.output.hose <- work
 	gf.output.increment()
 	gf.maintainRetry()
@@ -134,149 +420,220 @@ func (gf *Gofherd) maintainRetry() {
 
 func (gf *Gofherd) closeRetryChan() {
 	close(gf.retry.hose)
-	gf.logger.Printf("Closed retry chan\n")
+	gf.log.info("closed retry chan")
 	gf.retry.setClosedTrue()
 }
 
-func (gf *Gofherd) pushToRetryChan(work Work) {
+func (gf *Gofherd) pushToRetryChan(work Work, gopher int64) {
 	gf.registerRetry(&work)
 	work.incrementRetries()
+	gf.progress.retryPending()
+	// Cancel wakes the Work up, so that it is emitted as Cancelled without waiting out the backoff
+	wake, woken := context.WithCancel(context.Background())
+	gf.registry.retrying(work.ID, woken)
+	gf.events.publish(Event{Type: EventRetry, WorkID: work.ID, GopherID: gopher, Attempt: work.retryCount()})
+	backoff := time.Duration(atomic.LoadInt64(&(gf.retryBackoff)))
+	if work.retryAfter > 0 {
+		backoff = work.retryAfter
+	}
 	go func() {
+		defer woken()
+		if backoff > 0 {
+			select {
+			case <-gf.clock.After(backoff):
+			case <-wake.Done():
+			}
+		}
 		gf.retry.hose <- work
-		gf.logger.Printf("Pushed to retry, work: %s\n", work.ID)
+		gf.log.debug("pushed work to retry", append(workFields(&work), Field{"gopher_id", gopher})...)
 	}()
 	return
 }
 
-func (gf *Gofherd) receivedRetry(work Work, ok bool) bool {
+func (gf *Gofherd) receivedRetry(work Work, ok bool, gopher int64) bool {
 	if !ok {
 		gf.closeOutputChan()
 		return true
 	}
-	gf.logger.Printf("Received work from retry: %s\n", work.ID)
-	gf.handleInput(work)
+	gf.progress.retryPicked()
+	gf.log.debug("received work from retry", append(workFields(&work), Field{"gopher_id", gopher})...)
+	gf.handleInput(work, gopher)
 	return false
 }
 
-func (gf *Gofherd) initGopher() {
-	var work Work
-	var ok bool
+func (gf *Gofherd) initGopher(id int64, stop <-chan struct{}) {
+	defer gf.gophers.remove(id)
+	input := gf.input.hose
 	for {
+		// a retiring gopher stops before picking up more Work, even if some is ready
 		select {
-		case <-gf.quit:
-			gf.logger.Printf("Received quit, closing chan\n")
+		case <-stop:
+			gf.log.debug("retiring gopher", Field{"gopher_id", id})
 			return
-		case work, ok = <-gf.input.hose:
-			if !ok {
-				goto handleRetries
-			}
-			gf.logger.Printf("Received work from input: %s\n", work.ID)
-			gf.handleInput(work)
-		case work, ok = <-gf.retry.hose:
-			if quit := gf.receivedRetry(work, ok); quit {
-				return
-			}
+		default:
 		}
-	}
-handleRetries:
-	for {
 		select {
-		case <-gf.quit:
-			gf.logger.Printf("Received quit, closing chan\n")
+		case <-stop:
+			gf.log.debug("retiring gopher", Field{"gopher_id", id})
 			return
-		case work, ok = <-gf.retry.hose:
-			if quit := gf.receivedRetry(work, ok); quit {
+		case work, ok := <-input:
+			if !ok {
+				// input is done, only retries are left
+				input = nil
+				continue
+			}
+			gf.log.debug("received work from input", Field{"work_id", work.ID}, Field{"gopher_id", id})
+			gf.handleInput(work, id)
+		case work := <-gf.scheduler.due:
+			gf.log.debug("received scheduled work", Field{"work_id", work.ID}, Field{"gopher_id", id})
+			gf.handleInput(work, id)
+		case work, ok := <-gf.retry.hose:
+			if quit := gf.receivedRetry(work, ok, id); quit {
 				return
 			}
 		}
 	}
-	gf.closeOutputChan()
-
 }
 
-func (gf *Gofherd) handleInput(work Work) {
-	status := gf.processingLogic(&work)
+func (gf *Gofherd) handleInput(work Work, gopher int64) {
+	ctx, span := gf.tracer.Start(work.Context(), "gofherd.attempt",
+		Field{"work_id", work.ID}, Field{"attempt", work.retryCount() + 1}, Field{"gopher_id", gopher})
+	gf.limiter.wait(ctx)
+	var cancel context.CancelFunc
+	if timeout := atomic.LoadInt64(&(gf.workTimeout)); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout))
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+	if cancelled := gf.registry.started(work.ID, work.retryCount()+1, gopher, cancel); cancelled {
+		span.SetAttributes(Field{"status", Cancelled.String()})
+		span.End()
+		work.setStatus(Cancelled)
+		gf.pushToOutputChan(work)
+		return
+	}
+	work.attemptCtx = ctx
+	work.retryAfter = 0
+	gf.events.publish(Event{Type: EventStart, WorkID: work.ID, GopherID: gopher, Attempt: work.retryCount() + 1})
+	gf.runHooks(&work, EventHook.OnStart)
+	gf.progress.startProcessing()
+	gf.gophers.busy(gopher)
+	status := gf.processingLogic(ctx, &work)
+	gf.gophers.idle(gopher)
+	gf.progress.doneProcessing()
+	work.attemptCtx = nil
+	if ctx.Err() == context.DeadlineExceeded && status != Success && status != Failure && !gf.registry.isCancelled(work.ID) {
+		// a final status returned after the deadline stands, the Work may have side effects
+		gf.log.warn("work timed out", append(workFields(&work), Field{"gopher_id", gopher})...)
+		status = Retry
+	}
+	exhausted := work.retryCount() >= atomic.LoadInt64(&(gf.maxRetries))
+	// the status is decided by the registry, atomically with Cancel
+	status = gf.registry.finished(work.ID, status, exhausted)
+	span.SetAttributes(Field{"status", status.String()})
+	span.End()
 	work.setStatus(status)
-	if work.Status() == Success || work.Status() == Failure {
+	if work.Status() == Success || work.Status() == Failure || work.Status() == Cancelled {
 		gf.pushToOutputChan(work)
 		return
 	}
 
-	if work.Status() == Retry && work.retryCount() < gf.maxRetries {
-		gf.pushToRetryChan(work)
+	if work.Status() == Retry && !exhausted {
+		gf.pushToRetryChan(work, gopher)
 		return
 	}
+	gf.log.warn("work exhausted retries", append(workFields(&work), Field{"gopher_id", gopher})...)
 	work.setStatus(Failure)
 	gf.pushToOutputChan(work)
 }
 
 func (gf *Gofherd) registerRetry(w *Work) {
-	if gf.retryCallback != nil {
-		gf.retryCallback(w)
-	}
-	incrementRetryMetric()
+	gf.runHooks(w, EventHook.OnRetry)
+	gf.metrics.incrementRetry()
 }
 
 func (gf *Gofherd) registerSuccess(w *Work) {
-	if gf.successCallback != nil {
-		gf.successCallback(w)
-	}
-	incrementSuccessMetric()
+	gf.runHooks(w, EventHook.OnSuccess)
+	gf.metrics.incrementSuccess()
+}
+
+func (gf *Gofherd) registerCancelled(w *Work) {
+	gf.runHooks(w, EventHook.OnCancelled)
+	gf.metrics.incrementCancelled()
 }
 
 func (gf *Gofherd) registerFailure(w *Work) {
-	if gf.failureCallback != nil {
-		gf.failureCallback(w)
-	}
-	incrementFailureMetric()
+	gf.runHooks(w, EventHook.OnFailure)
+	gf.metrics.incrementFailure()
 }
 
 func (gf *Gofherd) updateHerdSize(num int64) (Status, string) {
 	if num < 0 {
 		msg := fmt.Sprintf("Herd size cannot be negative")
-		gf.logger.Printf(msg + "\n")
+		gf.log.warn("rejected herd size update", Field{"requested", num}, Field{"reason", msg})
 		return Retry, msg
 	}
-	oldSize := gf.herdSize
-	if oldSize == num {
-		msg := fmt.Sprintf("Herd size already %d", num)
-		gf.logger.Printf(msg + "\n")
-		return Success, msg
-	}
-
-	if num > oldSize {
-		gf.IncreasedHerdBy(num - oldSize)
-	}
-
-	if num < oldSize {
-		gf.DecreaseHerdBy(oldSize - num)
+	size, changed := gf.resize(func(int64) int64 { return num })
+	if !changed {
+		return Success, fmt.Sprintf("Herd size already %d", size.Target)
 	}
-
-	gf.SetHerdSize(num)
 	return Success, "success"
 }
 
 // IncreasedHerdBy is used to increase the herd size given amount
 func (gf *Gofherd) IncreasedHerdBy(num int64) {
-	for i := int64(0); i < num; i++ {
-		gf.logger.Printf("Starting gofher #%d\n", i)
-		go gf.initGopher()
-	}
+	gf.resize(func(target int64) int64 { return target + num })
 }
 
-// DecreaseHerdBy is used to decrease the herd size given amount
+// DecreaseHerdBy is used to decrease the herd size given amount. It does not wait for
+// gophers to stop, busy gophers finish their current Work first. The size does not go below 0.
 func (gf *Gofherd) DecreaseHerdBy(num int64) {
-	for i := int64(0); i < num; i++ {
-		gf.quit <- struct{}{}
-	}
+	gf.resize(func(target int64) int64 {
+		if num > target {
+			return 0
+		}
+		return target - num
+	})
 }
 
 // Start will start the processing and start the server. The function will return immediately.
-func (gf *Gofherd) Start() {
-	gf.logger.Printf("Starting server at %s\n", gf.addr)
-	mux := http.NewServeMux()
-	mux.Handle("/herd", http.HandlerFunc(gf.herdHandler))
-	mux.Handle("/metrics", promhttp.Handler())
-	go http.ListenAndServe(gf.addr, mux)
-	gf.IncreasedHerdBy(gf.herdSize)
+// It returns an error, without starting the processing, if the server cannot bind on `addr`.
+func (gf *Gofherd) Start() error {
+	if !gf.serverDisabled {
+		if err := gf.startServer(); err != nil {
+			gf.log.error("could not start server", Field{"addr", gf.addr}, Field{"error", err})
+			return err
+		}
+	}
+	gf.progress.markStarted()
+	if gf.progressEvery > 0 {
+		go gf.logProgress()
+	}
+	go gf.scheduler.run(gf.done, gf.dueWork)
+	go gf.runSchedules()
+	gf.herdMu.Lock()
+	gf.started = true
+	gf.reconcileHerd()
+	gf.herdMu.Unlock()
+	return nil
+}
+
+func (gf *Gofherd) startServer() error {
+	listener, err := net.Listen("tcp", gf.addr)
+	if err != nil {
+		return err
+	}
+	gf.log.info("starting server", Field{"addr", listener.Addr().String()}, Field{"tls", gf.tlsConfig != nil})
+	server := &http.Server{Handler: gf.Handler(), TLSConfig: gf.tlsConfig}
+	go func() {
+		var serveErr error
+		if gf.tlsConfig != nil {
+			serveErr = server.ServeTLS(listener, "", "")
+		} else {
+			serveErr = server.Serve(listener)
+		}
+		gf.log.error("server stopped", Field{"addr", gf.addr}, Field{"error", serveErr})
+	}()
+	return nil
 }
@@ -1,7 +1,9 @@
 package gofherd
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -13,6 +15,7 @@ func getBasicGopherd(maxRetries, workUnits, gofherdSize int, status Status) *Gof
 	gf := New(func(w *Work) Status { return status })
 	gf.SetHerdSize(int64(gofherdSize))
 	gf.SetMaxRetries(int64(maxRetries))
+	gf.SetAddr("127.0.0.1:0")
 
 	go func() {
 		for i := 0; i < workUnits; i++ {
@@ -128,13 +131,13 @@ func TestMetricIncrementOnPushToOutputChanWithSuccess(t *testing.T) {
 	gofherdSize := 1
 	gf := getBasicGopherd(maxRetries, workUnits, gofherdSize, Success)
 
-	oldVal := testutil.ToFloat64(successMetric)
+	oldVal := testutil.ToFloat64(gf.metrics.success)
 	expectedNewVal := oldVal + 1.0
 	gf.Start()
 
 	for i := 0; i < workUnits; i++ {
 		<-gf.output.hose
-		if newVal := testutil.ToFloat64(successMetric); newVal != expectedNewVal {
+		if newVal := testutil.ToFloat64(gf.metrics.success); newVal != expectedNewVal {
 			t.Fatalf("did not receive expected val in success metric, expected: %f, got: %f\n", expectedNewVal, newVal)
 		}
 	}
@@ -147,13 +150,13 @@ func TestMetricIncrementOnPushToOutputChanWithFailure(t *testing.T) {
 	gofherdSize := 1
 	gf := getBasicGopherd(maxRetries, workUnits, gofherdSize, Failure)
 
-	oldVal := testutil.ToFloat64(failureMetric)
+	oldVal := testutil.ToFloat64(gf.metrics.failure)
 	expectedNewVal := oldVal + 1.0
 	gf.Start()
 
 	for i := 0; i < workUnits; i++ {
 		<-gf.output.hose
-		if newVal := testutil.ToFloat64(failureMetric); newVal != expectedNewVal {
+		if newVal := testutil.ToFloat64(gf.metrics.failure); newVal != expectedNewVal {
 			t.Fatalf("did not receive expected val in failure metric, expected: %f, got: %f\n", expectedNewVal, newVal)
 		}
 	}
@@ -233,13 +236,13 @@ func TestMetricIncrementOnPushToRetryChan(t *testing.T) {
 	gofherdSize := 1
 	gf := getBasicGopherd(maxRetries, workUnits, gofherdSize, Retry)
 
-	oldVal := testutil.ToFloat64(retryMetric)
+	oldVal := testutil.ToFloat64(gf.metrics.retry)
 	expectedNewVal := oldVal + float64(maxRetries)
 	gf.Start()
 
 	for i := 0; i < workUnits; i++ {
 		<-gf.output.hose
-		if newVal := testutil.ToFloat64(retryMetric); newVal != expectedNewVal {
+		if newVal := testutil.ToFloat64(gf.metrics.retry); newVal != expectedNewVal {
 			t.Fatalf("did not receive expected val in retry metric, expected: %f, got: %f\n", expectedNewVal, newVal)
 		}
 	}
@@ -256,6 +259,7 @@ func TestProcessingLogicMakesUpdatesToWork(t *testing.T) {
 	})
 	gf.SetHerdSize(int64(gofherdSize))
 	gf.SetMaxRetries(int64(maxRetries))
+	gf.SetAddr("127.0.0.1:0")
 
 	go func() {
 		for i := 0; i < workUnits; i++ {
@@ -311,3 +315,262 @@ func TestGopherdUpdateHerdSize(t *testing.T) {
 	}
 	assertAllChannelsClosed(gf, t)
 }
+
+func TestGopherdOrderedOutput(t *testing.T) {
+	workUnits := 50
+	gf := New(func(w *Work) Status {
+		if w.Body.(int)%3 == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+		return Success
+	})
+	gf.SetHerdSize(8)
+	gf.SetOrderedOutput(10)
+	gf.SetAddr("127.0.0.1:0")
+
+	go func() {
+		for i := 0; i < workUnits; i++ {
+			gf.SendWork(Work{ID: fmt.Sprintf("%d", i), Body: i})
+		}
+		gf.CloseInputChan()
+	}()
+	gf.Start()
+
+	for i := 0; i < workUnits; i++ {
+		w := <-gf.output.hose
+		if w.Body.(int) != i {
+			t.Fatalf("did not receive work in order, expected: %d, got: %d\n", i, w.Body.(int))
+		}
+	}
+	assertAllChannelsClosed(gf, t)
+}
+
+func TestGopherdProgress(t *testing.T) {
+	maxRetries := 2
+	workUnits := 10
+	gofherdSize := 3
+	gf := getBasicGopherd(maxRetries, workUnits, gofherdSize, Retry)
+	gf.SetExpectedTotal(uint64(workUnits))
+	gf.Start()
+
+	for range gf.OutputChan() {
+	}
+	p := gf.Progress()
+	if p.Submitted != uint64(workUnits) || p.Failure != uint64(workUnits) || p.Success != 0 || p.InFlight != 0 || p.PendingRetries != 0 || p.ETA != 0 {
+		t.Fatalf("did not get expected progress after completion, got: %+v", p)
+	}
+	assertAllChannelsClosed(gf, t)
+}
+
+func TestGopherdStartReturnsErrorWhenAddrIsBound(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not bind a listener: %s", err)
+	}
+	defer listener.Close()
+
+	gf := New(func(w *Work) Status { return Success })
+	gf.SetAddr(listener.Addr().String())
+	if err := gf.Start(); err == nil {
+		t.Fatalf("expected an error on starting the server on a bound addr")
+	}
+}
+
+func TestGopherdDisableServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not bind a listener: %s", err)
+	}
+	defer listener.Close()
+
+	gf := getBasicGopherd(0, 1, 1, Success)
+	gf.SetAddr(listener.Addr().String())
+	gf.DisableServer()
+	if err := gf.Start(); err != nil {
+		t.Fatalf("expected no error on start with server disabled, got: %s", err)
+	}
+	for range gf.OutputChan() {
+	}
+	assertAllChannelsClosed(gf, t)
+}
+
+func TestGopherdWorkInfoAfterRetries(t *testing.T) {
+	maxRetries := 2
+	workUnits := 1
+	gofherdSize := 1
+	gf := getBasicGopherd(maxRetries, workUnits, gofherdSize, Retry)
+	gf.Start()
+	for range gf.OutputChan() {
+	}
+
+	info, ok := gf.WorkInfo("0")
+	if !ok || info.State != StateDone || info.Status != "failure" || len(info.Attempts) != maxRetries+1 {
+		t.Fatalf("did not get expected work info, got: %+v", info)
+	}
+	if len(gf.InFlight()) != 0 || len(gf.Retrying()) != 0 {
+		t.Fatalf("expected no in flight or retrying work after completion")
+	}
+	assertAllChannelsClosed(gf, t)
+}
+
+func TestGopherdCancelInFlightWork(t *testing.T) {
+	started := make(chan struct{})
+	gf := NewWithContext(func(ctx context.Context, w *Work) Status {
+		close(started)
+		<-ctx.Done()
+		return Retry
+	})
+	gf.SetHerdSize(1)
+	gf.SetMaxRetries(10)
+	gf.SetAddr("127.0.0.1:0")
+
+	go func() {
+		gf.SendWork(Work{ID: "abc"})
+		gf.CloseInputChan()
+	}()
+	gf.Start()
+
+	<-started
+	if err := gf.Cancel("abc"); err != nil {
+		t.Fatalf("could not cancel in flight work: %s", err)
+	}
+	w := <-gf.OutputChan()
+	if w.Status() != Cancelled || w.retryCount() != 0 {
+		t.Fatalf("did not receive expected status in output, expected: %s, got: %s\n", Cancelled, w.Status())
+	}
+	if err := gf.Cancel("abc"); err != ErrWorkDone {
+		t.Fatalf("expected ErrWorkDone on cancelling done work, got: %v", err)
+	}
+	assertAllChannelsClosed(gf, t)
+}
+
+func TestGopherdCancelRetryingWork(t *testing.T) {
+	attempts := int64(0)
+	gf := New(func(w *Work) Status {
+		atomic.AddInt64(&attempts, 1)
+		return Retry
+	})
+	gf.SetHerdSize(1)
+	gf.SetMaxRetries(10)
+	gf.SetAddr("127.0.0.1:0")
+	gf.AddRetryCallback(func(w *Work) { gf.Cancel(w.ID) })
+
+	go func() {
+		gf.SendWork(Work{ID: "abc"})
+		gf.CloseInputChan()
+	}()
+	gf.Start()
+
+	w := <-gf.OutputChan()
+	if w.Status() != Cancelled || atomic.LoadInt64(&attempts) != 1 {
+		t.Fatalf("expected cancelled work after a single attempt, got: %s after %d attempts\n", w.Status(), attempts)
+	}
+	assertAllChannelsClosed(gf, t)
+}
+
+func TestGopherdCancelWakesRetryBackoff(t *testing.T) {
+	gf := New(func(w *Work) Status { return Retry })
+	gf.SetHerdSize(1)
+	gf.SetMaxRetries(10)
+	gf.SetRetryBackoff(time.Hour)
+	gf.SetAddr("127.0.0.1:0")
+	go func() {
+		gf.SendWork(Work{ID: "abc"})
+		gf.CloseInputChan()
+	}()
+	gf.Start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(gf.Retrying()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := gf.Cancel("abc"); err != nil {
+		t.Fatalf("could not cancel: %s", err)
+	}
+	select {
+	case w := <-gf.OutputChan():
+		if w.Status() != Cancelled {
+			t.Fatalf("expected cancelled status, got: %s", w.Status())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected cancelled work to be emitted without waiting out the backoff")
+	}
+	assertAllChannelsClosed(gf, t)
+}
+
+func TestGopherdWorkTimeoutIsRetried(t *testing.T) {
+	maxRetries := 2
+	gf := NewWithContext(func(ctx context.Context, w *Work) Status {
+		<-ctx.Done()
+		return Retry
+	})
+	gf.SetHerdSize(1)
+	gf.SetMaxRetries(int64(maxRetries))
+	gf.SetWorkTimeout(10 * time.Millisecond)
+	gf.SetAddr("127.0.0.1:0")
+
+	go func() {
+		gf.SendWork(Work{ID: "abc"})
+		gf.CloseInputChan()
+	}()
+	gf.Start()
+
+	w := <-gf.OutputChan()
+	if w.Status() != Failure || w.retryCount() != int64(maxRetries) {
+		t.Fatalf("expected timed out work to fail after %d retries, got: %s after %d retries\n", maxRetries, w.Status(), w.retryCount())
+	}
+	assertAllChannelsClosed(gf, t)
+}
+
+func TestGopherdSuccessAfterWorkTimeoutStands(t *testing.T) {
+	attempts := int64(0)
+	gf := NewWithContext(func(ctx context.Context, w *Work) Status {
+		atomic.AddInt64(&attempts, 1)
+		<-ctx.Done()
+		return Success
+	})
+	gf.SetHerdSize(1)
+	gf.SetMaxRetries(2)
+	gf.SetWorkTimeout(10 * time.Millisecond)
+	gf.SetAddr("127.0.0.1:0")
+
+	go func() {
+		gf.SendWork(Work{ID: "abc"})
+		gf.CloseInputChan()
+	}()
+	gf.Start()
+
+	w := <-gf.OutputChan()
+	if w.Status() != Success || w.retryCount() != 0 || atomic.LoadInt64(&attempts) != 1 {
+		t.Fatalf("expected success returned after the deadline to stand, got: %s after %d retries, %d attempts\n", w.Status(), w.retryCount(), attempts)
+	}
+	assertAllChannelsClosed(gf, t)
+}
+
+func TestGopherdRetryBackoff(t *testing.T) {
+	maxRetries := 2
+	gf := getBasicGopherd(maxRetries, 1, 1, Retry)
+	gf.SetRetryBackoff(20 * time.Millisecond)
+
+	start := time.Now()
+	gf.Start()
+	<-gf.OutputChan()
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("expected %d retries with 20ms backoff to take at least 40ms, took: %s", maxRetries, elapsed)
+	}
+	assertAllChannelsClosed(gf, t)
+}
+
+func TestGopherdGophersAreTracked(t *testing.T) {
+	workUnits := 4
+	gofherdSize := 2
+	gf := getBasicGopherd(0, workUnits, gofherdSize, Success)
+	gf.Start()
+	if running := len(gf.Gophers()); running != gofherdSize {
+		t.Fatalf("expected %d running gophers, got: %d", gofherdSize, running)
+	}
+
+	for range gf.OutputChan() {
+	}
+	assertAllChannelsClosed(gf, t)
+}
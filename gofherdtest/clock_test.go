@@ -0,0 +1,54 @@
+package gofherdtest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockAdvance(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewFakeClock(start)
+	later := clock.After(2 * time.Second)
+	sooner := clock.After(time.Second)
+
+	select {
+	case <-clock.After(0):
+	default:
+		t.Fatalf("expected After(0) to fire immediately")
+	}
+	if clock.Waiters() != 2 {
+		t.Fatalf("expected 2 waiters, got: %d", clock.Waiters())
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case now := <-sooner:
+		if !now.Equal(start.Add(time.Second)) {
+			t.Fatalf("expected to fire at %s, got: %s", start.Add(time.Second), now)
+		}
+	default:
+		t.Fatalf("expected After(1s) to fire after advancing 1s")
+	}
+	select {
+	case <-later:
+		t.Fatalf("expected After(2s) to not fire after advancing 1s")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	<-later
+	if clock.Waiters() != 0 || !clock.Now().Equal(start.Add(2*time.Second)) {
+		t.Fatalf("expected no waiters at %s, got: %d at %s", start.Add(2*time.Second), clock.Waiters(), clock.Now())
+	}
+}
+
+func TestFakeClockBlockUntilWaiters(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	if clock.BlockUntilWaiters(1, 10*time.Millisecond) {
+		t.Fatalf("expected BlockUntilWaiters to time out with no waiters")
+	}
+	go clock.After(time.Minute)
+	if !clock.BlockUntilWaiters(1, time.Second) {
+		t.Fatalf("expected BlockUntilWaiters to see the waiter")
+	}
+}
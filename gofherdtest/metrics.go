@@ -0,0 +1,50 @@
+package gofherdtest
+
+import (
+	"testing"
+
+	gf "github.com/darshanime/gofherd"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// IsolatedMetrics makes the herd report to a new registry, which is returned, so that
+// metric assertions are not affected by other herds in the same test binary.
+func IsolatedMetrics(herd *gf.Gofherd) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	herd.SetMetricsRegistry(reg)
+	return reg
+}
+
+// MetricValue returns the value of the counter or gauge with the name, failing the test if it is missing.
+func MetricValue(t testing.TB, g prometheus.Gatherer, name string) float64 {
+	t.Helper()
+	families, err := g.Gather()
+	if err != nil {
+		t.Fatalf("could not gather metrics: %s", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		value := 0.0
+		for _, m := range family.GetMetric() {
+			switch {
+			case m.GetCounter() != nil:
+				value += m.GetCounter().GetValue()
+			case m.GetGauge() != nil:
+				value += m.GetGauge().GetValue()
+			}
+		}
+		return value
+	}
+	t.Fatalf("metric %s not found", name)
+	return 0
+}
+
+// AssertMetric fails the test unless the counter or gauge with the name has the expected value.
+func AssertMetric(t testing.TB, g prometheus.Gatherer, name string, expected float64) {
+	t.Helper()
+	if value := MetricValue(t, g, name); value != expected {
+		t.Errorf("metric %s is %f, expected: %f", name, value, expected)
+	}
+}
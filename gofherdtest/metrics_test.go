@@ -0,0 +1,21 @@
+package gofherdtest
+
+import (
+	"testing"
+
+	gf "github.com/darshanime/gofherd"
+)
+
+func TestIsolatedMetrics(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		herd := gf.New(NewScript(gf.Success).On("b", gf.Retry, gf.Success).On("c", gf.Failure).Process)
+		herd.SetHerdSize(2)
+		herd.SetMaxRetries(1)
+		reg := IsolatedMetrics(herd)
+
+		Run(t, herd, Work("a", "b", "c"), 0)
+		AssertMetric(t, reg, "gofherd_success_total", 2)
+		AssertMetric(t, reg, "gofherd_retry_total", 1)
+		AssertMetric(t, reg, "gofherd_failure_total", 1)
+	}
+}
@@ -0,0 +1,87 @@
+package gofherdtest
+
+import (
+	"sync"
+	"testing"
+
+	gf "github.com/darshanime/gofherd"
+)
+
+// Recorder is an EventHook which records the ID of the Work every hook is called with.
+// Add it to a herd with AddHook and assert on the calls with AssertCalls.
+type Recorder struct {
+	gf.BaseHook
+	mu      sync.Mutex
+	calls   map[gf.EventType][]string
+	resizes [][2]int64
+}
+
+// NewRecorder initializes a new Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{calls: make(map[gf.EventType][]string)}
+}
+
+func (r *Recorder) record(event gf.EventType, w *gf.Work) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls[event] = append(r.calls[event], w.ID)
+}
+
+// OnEnqueue records an EventEnqueue call.
+func (r *Recorder) OnEnqueue(w *gf.Work) { r.record(gf.EventEnqueue, w) }
+
+// OnStart records an EventStart call.
+func (r *Recorder) OnStart(w *gf.Work) { r.record(gf.EventStart, w) }
+
+// OnRetry records an EventRetry call.
+func (r *Recorder) OnRetry(w *gf.Work) { r.record(gf.EventRetry, w) }
+
+// OnSuccess records an EventSuccess call.
+func (r *Recorder) OnSuccess(w *gf.Work) { r.record(gf.EventSuccess, w) }
+
+// OnFailure records an EventFailure call.
+func (r *Recorder) OnFailure(w *gf.Work) { r.record(gf.EventFailure, w) }
+
+// OnCancelled records an EventCancelled call.
+func (r *Recorder) OnCancelled(w *gf.Work) { r.record(gf.EventCancelled, w) }
+
+// OnHerdResize records a herd resize.
+func (r *Recorder) OnHerdResize(from, to int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resizes = append(r.resizes, [2]int64{from, to})
+}
+
+// Calls returns the IDs of the Work the hook for the event was called with, in call order.
+func (r *Recorder) Calls(event gf.EventType) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.calls[event]...)
+}
+
+// Resizes returns the from and to sizes of every herd resize.
+func (r *Recorder) Resizes() [][2]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][2]int64{}, r.resizes...)
+}
+
+// AssertCalls fails the test unless the hook for the event was called the expected
+// number of times for each Work ID, and not for any other Work.
+func AssertCalls(t testing.TB, r *Recorder, event gf.EventType, expected map[string]int) {
+	t.Helper()
+	got := make(map[string]int)
+	for _, id := range r.Calls(event) {
+		got[id]++
+	}
+	for id, num := range expected {
+		if got[id] != num {
+			t.Errorf("%s hook called %d times for work %s, expected: %d", event, got[id], id, num)
+		}
+	}
+	for id, num := range got {
+		if _, ok := expected[id]; !ok {
+			t.Errorf("%s hook unexpectedly called %d times for work %s", event, num, id)
+		}
+	}
+}
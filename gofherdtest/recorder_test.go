@@ -0,0 +1,25 @@
+package gofherdtest
+
+import (
+	"testing"
+
+	gf "github.com/darshanime/gofherd"
+)
+
+func TestRecorderCalls(t *testing.T) {
+	recorder := NewRecorder()
+	herd := gf.New(NewScript(gf.Success).On("b", gf.Retry, gf.Success).On("c", gf.Failure).Process)
+	herd.SetHerdSize(2)
+	herd.SetMaxRetries(1)
+	herd.AddHook(recorder)
+
+	Run(t, herd, Work("a", "b", "c"), 0)
+	AssertCalls(t, recorder, gf.EventEnqueue, map[string]int{"a": 1, "b": 1, "c": 1})
+	AssertCalls(t, recorder, gf.EventStart, map[string]int{"a": 1, "b": 2, "c": 1})
+	AssertCalls(t, recorder, gf.EventRetry, map[string]int{"b": 1})
+	AssertCalls(t, recorder, gf.EventSuccess, map[string]int{"a": 1, "b": 1})
+	AssertCalls(t, recorder, gf.EventFailure, map[string]int{"c": 1})
+	if resizes := recorder.Resizes(); len(resizes) != 0 {
+		t.Fatalf("expected no resizes, got: %v", resizes)
+	}
+}
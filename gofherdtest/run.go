@@ -0,0 +1,111 @@
+package gofherdtest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	gf "github.com/darshanime/gofherd"
+)
+
+// DefaultTimeout is used by Run when no timeout is given.
+const DefaultTimeout = 10 * time.Second
+
+// Result is the Work received from the output chan, by ID.
+type Result map[string]gf.Work
+
+// Run starts the herd with the server disabled, sends the Work, closes the input chan
+// and collects the output until the output chan is closed. If that does not happen
+// within the timeout, the herd has most likely deadlocked and the test fails.
+func Run(t testing.TB, herd *gf.Gofherd, work []gf.Work, timeout time.Duration) Result {
+	t.Helper()
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	herd.DisableServer()
+	if err := herd.Start(); err != nil {
+		t.Fatalf("could not start the herd: %s", err)
+	}
+	go func() {
+		for _, w := range work {
+			herd.SendWork(w)
+		}
+		herd.CloseInputChan()
+	}()
+
+	result := Result{}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		select {
+		case w, ok := <-herd.OutputChan():
+			if !ok {
+				return result
+			}
+			if _, dup := result[w.ID]; dup {
+				t.Errorf("received work with ID %s more than once", w.ID)
+			}
+			result[w.ID] = w
+		case <-deadline.C:
+			t.Fatalf("herd did not complete within %s, it may be deadlocked, received: %d, progress: %+v", timeout, len(result), herd.Progress())
+		}
+	}
+}
+
+// Work returns Work units with the IDs.
+func Work(ids ...string) []gf.Work {
+	work := make([]gf.Work, 0, len(ids))
+	for _, id := range ids {
+		work = append(work, gf.Work{ID: id})
+	}
+	return work
+}
+
+// NumberedWork returns num Work units, with IDs from "0" to num-1.
+func NumberedWork(num int) []gf.Work {
+	work := make([]gf.Work, 0, num)
+	for i := 0; i < num; i++ {
+		work = append(work, gf.Work{ID: fmt.Sprintf("%d", i)})
+	}
+	return work
+}
+
+// AssertStatuses fails the test unless the Work with each ID reached the expected status.
+func AssertStatuses(t testing.TB, result Result, expected map[string]gf.Status) {
+	t.Helper()
+	for id, status := range expected {
+		w, ok := result[id]
+		if !ok {
+			t.Errorf("work %s was not received from the output chan", id)
+			continue
+		}
+		if w.Status() != status {
+			t.Errorf("work %s has status %s, expected: %s", id, w.Status(), status)
+		}
+	}
+}
+
+// AssertAllStatus fails the test unless every Work in the result reached the status.
+func AssertAllStatus(t testing.TB, result Result, status gf.Status) {
+	t.Helper()
+	for id, w := range result {
+		if w.Status() != status {
+			t.Errorf("work %s has status %s, expected: %s", id, w.Status(), status)
+		}
+	}
+}
+
+// AssertRetries fails the test unless the Work with each ID was retried the expected number of times.
+func AssertRetries(t testing.TB, result Result, expected map[string]int64) {
+	t.Helper()
+	for id, retries := range expected {
+		w, ok := result[id]
+		if !ok {
+			t.Errorf("work %s was not received from the output chan", id)
+			continue
+		}
+		if w.Retries() != retries {
+			t.Errorf("work %s was retried %d times, expected: %d", id, w.Retries(), retries)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package gofherdtest
+
+import (
+	"testing"
+	"time"
+
+	gf "github.com/darshanime/gofherd"
+)
+
+func TestRunToCompletion(t *testing.T) {
+	script := NewScript(gf.Success).On("1", gf.Retry, gf.Success).On("2", gf.Failure)
+	herd := gf.New(script.Process)
+	herd.SetHerdSize(3)
+	herd.SetMaxRetries(2)
+
+	result := Run(t, herd, NumberedWork(10), 0)
+	if len(result) != 10 {
+		t.Fatalf("expected 10 work units, got: %d", len(result))
+	}
+	AssertStatuses(t, result, map[string]gf.Status{"0": gf.Success, "1": gf.Success, "2": gf.Failure})
+	AssertRetries(t, result, map[string]int64{"0": 0, "1": 1, "2": 0})
+}
+
+func TestRunRetriesWithFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	script := NewScript(gf.Retry)
+	herd := gf.New(script.Process)
+	herd.SetHerdSize(1)
+	herd.SetMaxRetries(3)
+	herd.SetClock(clock)
+	if err := herd.SetRetryBackoff(time.Hour); err != nil {
+		t.Fatalf("could not set retry backoff: %s", err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if clock.BlockUntilWaiters(1, 10*time.Millisecond) {
+				clock.Advance(time.Hour)
+			}
+		}
+	}()
+
+	result := Run(t, herd, Work("a"), 0)
+	AssertAllStatus(t, result, gf.Failure)
+	AssertRetries(t, result, map[string]int64{"a": 3})
+	if elapsed := clock.Now().Sub(time.Unix(0, 0)); elapsed != 3*time.Hour {
+		t.Fatalf("expected 3 hours of backoff on the fake clock, got: %s", elapsed)
+	}
+}
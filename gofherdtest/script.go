@@ -0,0 +1,53 @@
+package gofherdtest
+
+import (
+	"sync"
+
+	gf "github.com/darshanime/gofherd"
+)
+
+// Script is processing logic which returns the statuses scripted for each Work ID,
+// one per attempt. Work without a script gets the default status.
+type Script struct {
+	mu       sync.Mutex
+	def      gf.Status
+	statuses map[string][]gf.Status
+	attempts map[string]int
+}
+
+// NewScript initializes a new Script returning def for Work without a script.
+func NewScript(def gf.Status) *Script {
+	return &Script{def: def, statuses: make(map[string][]gf.Status), attempts: make(map[string]int)}
+}
+
+// On scripts the statuses returned for the attempts of the Work with the ID, in order.
+// The last status is returned again for any further attempts.
+func (s *Script) On(id string, statuses ...gf.Status) *Script {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[id] = statuses
+	return s
+}
+
+// Process is the processing logic, to be passed to gofherd.New.
+func (s *Script) Process(w *gf.Work) gf.Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempt := s.attempts[w.ID]
+	s.attempts[w.ID]++
+	statuses, ok := s.statuses[w.ID]
+	if !ok || len(statuses) == 0 {
+		return s.def
+	}
+	if attempt >= len(statuses) {
+		return statuses[len(statuses)-1]
+	}
+	return statuses[attempt]
+}
+
+// Attempts returns the number of times the processing logic ran for the Work with the ID.
+func (s *Script) Attempts(id string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts[id]
+}
@@ -0,0 +1,28 @@
+package gofherdtest
+
+import (
+	"testing"
+
+	gf "github.com/darshanime/gofherd"
+)
+
+func TestScriptReturnsStatusPerAttempt(t *testing.T) {
+	script := NewScript(gf.Success).On("a", gf.Retry, gf.Failure).On("b", gf.Retry)
+
+	expected := []struct {
+		id     string
+		status gf.Status
+	}{
+		{"a", gf.Retry}, {"a", gf.Failure}, {"a", gf.Failure},
+		{"b", gf.Retry}, {"b", gf.Retry},
+		{"c", gf.Success},
+	}
+	for _, tc := range expected {
+		if status := script.Process(&gf.Work{ID: tc.id}); status != tc.status {
+			t.Fatalf("expected %s for work %s, got: %s", tc.status, tc.id, status)
+		}
+	}
+	if script.Attempts("a") != 3 || script.Attempts("c") != 1 || script.Attempts("d") != 0 {
+		t.Fatalf("did not get expected attempts, got: a=%d c=%d d=%d", script.Attempts("a"), script.Attempts("c"), script.Attempts("d"))
+	}
+}
@@ -0,0 +1,174 @@
+package gofherd
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// GopherStats is the busy and idle accounting of a running gopher.
+// A gopher is busy while running the processing logic.
+type GopherStats struct {
+	ID          int64     `json:"id"`
+	Started     time.Time `json:"started"`
+	Busy        bool      `json:"busy"`
+	BusySeconds float64   `json:"busy_seconds"`
+	IdleSeconds float64   `json:"idle_seconds"`
+	Processed   uint64    `json:"processed"`
+}
+
+type gopherStat struct {
+	started   time.Time
+	busy      time.Duration
+	busySince time.Time
+	processed uint64
+	stop      chan struct{}
+	retiring  bool
+}
+
+// gopherTracker keeps track of every running gopher, so that the herd can be resized
+// without relying on gophers being idle, and accounts for their busy and idle time.
+type gopherTracker struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	gophers map[int64]*gopherStat
+	updated time.Time
+	metrics *metrics
+}
+
+func newGopherTracker(m *metrics) *gopherTracker {
+	return &gopherTracker{now: time.Now, gophers: make(map[int64]*gopherStat), metrics: m}
+}
+
+// add registers a gopher and returns the chan closed when it should retire.
+func (gt *gopherTracker) add(id int64) <-chan struct{} {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	stat := &gopherStat{started: gt.now(), stop: make(chan struct{})}
+	gt.gophers[id] = stat
+	return stat.stop
+}
+
+func (gt *gopherTracker) remove(id int64) {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	delete(gt.gophers, id)
+	gt.metrics.setUtilization(gt.utilization(gt.now()))
+}
+
+func (gt *gopherTracker) running() int64 {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	return int64(len(gt.gophers))
+}
+
+// active returns the number of running gophers which have not been asked to retire.
+func (gt *gopherTracker) active() int64 {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	active := int64(0)
+	for _, stat := range gt.gophers {
+		if !stat.retiring {
+			active++
+		}
+	}
+	return active
+}
+
+// retire asks up to num gophers to retire, idle ones first. It does not wait for them:
+// a busy gopher finishes its current Work before it stops.
+func (gt *gopherTracker) retire(num int64) {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	candidates := make([]int64, 0, len(gt.gophers))
+	for id, stat := range gt.gophers {
+		if !stat.retiring {
+			candidates = append(candidates, id)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		iBusy, jBusy := !gt.gophers[candidates[i]].busySince.IsZero(), !gt.gophers[candidates[j]].busySince.IsZero()
+		if iBusy != jBusy {
+			return jBusy
+		}
+		return candidates[i] > candidates[j]
+	})
+	for i := int64(0); i < num && i < int64(len(candidates)); i++ {
+		stat := gt.gophers[candidates[i]]
+		stat.retiring = true
+		close(stat.stop)
+	}
+}
+
+func (gt *gopherTracker) busy(id int64) {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	if stat, ok := gt.gophers[id]; ok {
+		stat.busySince = gt.now()
+	}
+}
+
+// idle marks the end of an attempt. The utilization gauge is updated at most once a second.
+func (gt *gopherTracker) idle(id int64) {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	now := gt.now()
+	if stat, ok := gt.gophers[id]; ok && !stat.busySince.IsZero() {
+		stat.busy += now.Sub(stat.busySince)
+		stat.busySince = time.Time{}
+		stat.processed++
+	}
+	if now.Sub(gt.updated) >= time.Second {
+		gt.updated = now
+		gt.metrics.setUtilization(gt.utilization(now))
+	}
+}
+
+// busyTime returns the busy time of the gopher including the current attempt.
+func (stat *gopherStat) busyTime(now time.Time) time.Duration {
+	busy := stat.busy
+	if !stat.busySince.IsZero() {
+		busy += now.Sub(stat.busySince)
+	}
+	return busy
+}
+
+// utilization is the fraction of time the running gophers have been busy since they started.
+// It must be called with the lock held.
+func (gt *gopherTracker) utilization(now time.Time) float64 {
+	var busy, alive time.Duration
+	for _, stat := range gt.gophers {
+		busy += stat.busyTime(now)
+		alive += now.Sub(stat.started)
+	}
+	if alive <= 0 {
+		return 0
+	}
+	return float64(busy) / float64(alive)
+}
+
+func (gt *gopherTracker) utilizationNow() float64 {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	return gt.utilization(gt.now())
+}
+
+func (gt *gopherTracker) stats() []GopherStats {
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	now := gt.now()
+	stats := make([]GopherStats, 0, len(gt.gophers))
+	for id, stat := range gt.gophers {
+		busy := stat.busyTime(now)
+		stats = append(stats, GopherStats{
+			ID:          id,
+			Started:     stat.started,
+			Busy:        !stat.busySince.IsZero(),
+			BusySeconds: busy.Seconds(),
+			IdleSeconds: (now.Sub(stat.started) - busy).Seconds(),
+			Processed:   stat.processed,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ID < stats[j].ID })
+	return stats
+}
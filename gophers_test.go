@@ -0,0 +1,39 @@
+package gofherd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGopherTrackerAccounting(t *testing.T) {
+	now := time.Unix(1000, 0)
+	gt := newGopherTracker(defaultMetrics)
+	gt.now = func() time.Time { return now }
+
+	gt.add(1)
+	gt.add(2)
+	now = now.Add(2 * time.Second)
+	gt.busy(1)
+	now = now.Add(6 * time.Second)
+	gt.idle(1)
+	now = now.Add(2 * time.Second)
+
+	stats := gt.stats()
+	if len(stats) != 2 || gt.running() != 2 {
+		t.Fatalf("expected 2 running gophers, got: %+v", stats)
+	}
+	if stats[0].BusySeconds != 6 || stats[0].IdleSeconds != 4 || stats[0].Processed != 1 || stats[0].Busy {
+		t.Fatalf("did not get expected stats for gopher 1, got: %+v", stats[0])
+	}
+	if stats[1].BusySeconds != 0 || stats[1].IdleSeconds != 10 {
+		t.Fatalf("did not get expected stats for gopher 2, got: %+v", stats[1])
+	}
+	if utilization := gt.utilizationNow(); utilization != 0.3 {
+		t.Fatalf("did not get expected utilization, expected: %f, got: %f", 0.3, utilization)
+	}
+
+	gt.remove(2)
+	if gt.running() != 1 {
+		t.Fatalf("expected 1 running gopher after remove, got: %d", gt.running())
+	}
+}
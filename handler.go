@@ -1,22 +1,99 @@
 package gofherd
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 )
 
-type herd struct {
-	Num int64  `json:"num"`
+// Handler returns the control handlers, `/herd`, `/progress`, `/work/`, `/config`, `/events`,
+// `/debug/gophers`, `/dashboard` and `/metrics`, along with the ones added with Handle,
+// so that they can be mounted on a custom server. It is used by the server started by Start.
+func (gf *Gofherd) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, r := range gf.routes {
+		mux.Handle(r.pattern, r.handler)
+	}
+	mux.Handle("/herd", http.HandlerFunc(gf.herdHandler))
+	mux.Handle("/progress", http.HandlerFunc(gf.progressHandler))
+	mux.Handle("/work/", http.HandlerFunc(gf.workHandler))
+	mux.Handle("/config", http.HandlerFunc(gf.configHandler))
+	mux.Handle("/schedules", http.HandlerFunc(gf.schedulesHandler))
+	mux.Handle("/events", http.HandlerFunc(gf.eventsHandler))
+	mux.Handle("/debug/gophers", http.HandlerFunc(gf.gophersHandler))
+	mux.Handle("/dashboard", http.HandlerFunc(gf.dashboardHandler))
+	mux.Handle("/dashboard/events", http.HandlerFunc(gf.dashboardEventsHandler))
+	mux.Handle("/metrics", gf.metrics.handler())
+	return gf.authenticate(mux)
+}
+
+type route struct {
+	pattern string
+	handler http.Handler
+}
+
+// Handle adds a handler to the ones returned by Handler, behind the same authentication.
+// It must be called before Start.
+func (gf *Gofherd) Handle(pattern string, handler http.Handler) {
+	gf.routes = append(gf.routes, route{pattern: pattern, handler: handler})
+}
+
+// authCookie holds the auth token for browsers, which cannot set the Authorization
+// header on the requests made by the dashboard.
+const authCookie = "gofherd_token"
+
+func (gf *Gofherd) authenticate(next http.Handler) http.Handler {
+	if gf.authToken == "" {
+		return next
+	}
+	expected := []byte("Bearer " + gf.authToken)
+	token := []byte(gf.authToken)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// opening `/dashboard?token=<token>` stores the token in a cookie, and drops it from the URL
+		if query := r.URL.Query().Get("token"); query != "" && r.URL.Path == "/dashboard" {
+			if subtle.ConstantTimeCompare([]byte(query), token) != 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: authCookie, Value: gf.authToken, Path: "/",
+				HttpOnly: true, SameSite: http.SameSiteStrictMode, Secure: r.TLS != nil})
+			// a relative location, since the handlers may be mounted under a prefix
+			w.Header().Set("Location", "dashboard")
+			w.WriteHeader(http.StatusSeeOther)
+			return
+		}
+		authorized := subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) == 1
+		if cookie, err := r.Cookie(authCookie); !authorized && err == nil {
+			authorized = subtle.ConstantTimeCompare([]byte(cookie.Value), token) == 1
+		}
+		if !authorized {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type message struct {
 	Msg string `json:"msg"`
 }
 
+type herd struct {
+	Num     int64  `json:"num"`
+	Running int64  `json:"running"`
+	Msg     string `json:"msg"`
+}
+
 func (gf *Gofherd) herdHandler(w http.ResponseWriter, r *http.Request) {
 	var response []byte
 	w.Header().Set("Content-Type", "application/json")
 	switch r.Method {
 	case http.MethodGet:
-		foo := herd{Num: gf.herdSize, Msg: "success"}
+		size := gf.HerdSize()
+		foo := herd{Num: size.Target, Running: size.Running, Msg: "success"}
 		response, _ = json.Marshal(foo)
 		fmt.Fprintf(w, string(response))
 		return
@@ -24,7 +101,8 @@ func (gf *Gofherd) herdHandler(w http.ResponseWriter, r *http.Request) {
 		var herdPatch herd
 		json.NewDecoder(r.Body).Decode(&herdPatch)
 		status, msg := gf.updateHerdSize(herdPatch.Num)
-		response, _ = json.Marshal(herd{Num: gf.herdSize, Msg: msg})
+		size := gf.HerdSize()
+		response, _ = json.Marshal(herd{Num: size.Target, Running: size.Running, Msg: msg})
 		if status == Retry {
 			w.WriteHeader(http.StatusBadRequest)
 		}
@@ -36,3 +114,210 @@ func (gf *Gofherd) herdHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 }
+
+type progress struct {
+	Submitted      uint64     `json:"submitted"`
+	Expected       uint64     `json:"expected"`
+	Success        uint64     `json:"success"`
+	Failure        uint64     `json:"failure"`
+	Cancelled      uint64     `json:"cancelled"`
+	Retries        uint64     `json:"retries"`
+	InFlight       int64      `json:"in_flight"`
+	PendingRetries int64      `json:"pending_retries"`
+	Throughput     Throughput `json:"throughput"`
+	ETASeconds     float64    `json:"eta_seconds"`
+}
+
+func (gf *Gofherd) progressHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	response, _ := json.Marshal(newProgressResponse(gf.Progress()))
+	w.Write(response)
+}
+
+func newProgressResponse(p Progress) progress {
+	return progress{
+		Submitted:      p.Submitted,
+		Expected:       p.Expected,
+		Success:        p.Success,
+		Failure:        p.Failure,
+		Cancelled:      p.Cancelled,
+		Retries:        p.Retries,
+		InFlight:       p.InFlight,
+		PendingRetries: p.PendingRetries,
+		Throughput:     p.Throughput,
+		ETASeconds:     p.ETA.Seconds(),
+	}
+}
+
+func (gf *Gofherd) workHandler(w http.ResponseWriter, r *http.Request) {
+	var response []byte
+	w.Header().Set("Content-Type", "application/json")
+	id := strings.TrimPrefix(r.URL.Path, "/work/")
+	switch r.Method {
+	case http.MethodGet:
+		switch id {
+		case "inflight":
+			response, _ = json.Marshal(gf.InFlight())
+		case "retrying":
+			response, _ = json.Marshal(gf.Retrying())
+		default:
+			info, ok := gf.WorkInfo(id)
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				response, _ = json.Marshal(message{Msg: fmt.Sprintf("Work %s not found", id)})
+				w.Write(response)
+				return
+			}
+			response, _ = json.Marshal(info)
+		}
+		w.Write(response)
+		return
+	case http.MethodDelete:
+		err := gf.Cancel(id)
+		switch err {
+		case nil:
+			response, _ = json.Marshal(message{Msg: "cancelled"})
+		case ErrWorkNotFound:
+			w.WriteHeader(http.StatusNotFound)
+			response, _ = json.Marshal(message{Msg: fmt.Sprintf("Work %s not found", id)})
+		default:
+			w.WriteHeader(http.StatusConflict)
+			response, _ = json.Marshal(message{Msg: err.Error()})
+		}
+		w.Write(response)
+		return
+	}
+	w.WriteHeader(http.StatusMethodNotAllowed)
+}
+
+type config struct {
+	MaxRetries   int64   `json:"max_retries"`
+	RetryBackoff string  `json:"retry_backoff"`
+	RateLimit    float64 `json:"rate_limit"`
+	WorkTimeout  string  `json:"work_timeout"`
+	Msg          string  `json:"msg"`
+}
+
+func newConfigResponse(c Config, msg string) config {
+	return config{
+		MaxRetries:   c.MaxRetries,
+		RetryBackoff: c.RetryBackoff.String(),
+		RateLimit:    c.RateLimit,
+		WorkTimeout:  c.WorkTimeout.String(),
+		Msg:          msg,
+	}
+}
+
+func (gf *Gofherd) configHandler(w http.ResponseWriter, r *http.Request) {
+	var response []byte
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case http.MethodGet:
+		response, _ = json.Marshal(newConfigResponse(gf.Config(), "success"))
+		w.Write(response)
+		return
+	case http.MethodPatch:
+		var patch configPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			response, _ = json.Marshal(message{Msg: fmt.Sprintf("invalid body: %s", err)})
+			w.Write(response)
+			return
+		}
+		c, err := patch.apply(gf.Config())
+		if err == nil {
+			err = gf.SetConfig(c)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			response, _ = json.Marshal(message{Msg: err.Error()})
+			w.Write(response)
+			return
+		}
+		response, _ = json.Marshal(newConfigResponse(gf.Config(), "success"))
+		w.Write(response)
+		return
+	}
+	w.WriteHeader(http.StatusMethodNotAllowed)
+}
+
+// eventsHandler streams lifecycle events as server-sent events, or as newline delimited JSON
+// with `?format=ndjson`. Events can be filtered with `?type=success,failure`.
+// Dropped events are reported with a `dropped` event carrying the total dropped so far.
+func (gf *Gofherd) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	var types []EventType
+	if filter := r.URL.Query().Get("type"); filter != "" {
+		for _, t := range strings.Split(filter, ",") {
+			types = append(types, EventType(strings.TrimSpace(t)))
+		}
+	}
+	ndjson := r.URL.Query().Get("format") == "ndjson"
+	if ndjson {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	sub := gf.events.subscribe(types...)
+	defer gf.events.unsubscribe(sub)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var dropped uint64
+	write := func(eventType string, v interface{}) {
+		data, _ := json.Marshal(v)
+		if ndjson {
+			fmt.Fprintf(w, "%s\n", data)
+		} else {
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, data)
+		}
+	}
+	send := func(event Event) {
+		if d := sub.droppedCount(); d != dropped {
+			dropped = d
+			write("dropped", map[string]interface{}{"type": "dropped", "dropped": dropped})
+		}
+		write(string(event.Type), event)
+		flusher.Flush()
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-sub.events:
+			send(event)
+		case <-gf.done:
+			for {
+				select {
+				case event := <-sub.events:
+					send(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (gf *Gofherd) gophersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	response, _ := json.Marshal(gf.Gophers())
+	w.Write(response)
+}
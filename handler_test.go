@@ -2,6 +2,7 @@ package gofherd
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -52,7 +53,7 @@ func TestHerdGet(t *testing.T) {
 			contentTypeHeaderValue, "application/json")
 	}
 
-	expected := `{"num":15,"msg":"success"}`
+	expected := `{"num":15,"running":0,"msg":"success"}`
 	if resp.Body.String() != expected {
 		t.Errorf("handler returned unexpected body: got %v want %v",
 			resp.Body.String(), expected)
@@ -87,9 +88,232 @@ func TestHerdPatch(t *testing.T) {
 			contentTypeHeaderValue, "application/json")
 	}
 
-	expected := `{"num":10,"msg":"success"}`
+	expected := `{"num":10,"running":10,"msg":"success"}`
 	if resp.Body.String() != expected {
 		t.Errorf("handler returned unexpected body: got %v want %v",
 			resp.Body.String(), expected)
 	}
 }
+
+func TestProgressGet(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	gf.SetExpectedTotal(5)
+
+	resp := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/progress", nil)
+	if err != nil {
+		t.Fatalf("failed to create a request")
+	}
+
+	handler := http.HandlerFunc(gf.progressHandler)
+	handler.ServeHTTP(resp, req)
+
+	if status := resp.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusOK)
+	}
+
+	expected := `{"submitted":0,"expected":5,"success":0,"failure":0,"cancelled":0,"retries":0,"in_flight":0,"pending_retries":0,"throughput":{"1m":0,"5m":0,"15m":0},"eta_seconds":0}`
+	if resp.Body.String() != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v",
+			resp.Body.String(), expected)
+	}
+}
+
+func TestHandlerRequiresAuthToken(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	gf.SetAuthToken("s3cret")
+	handler := gf.Handler()
+
+	for _, tc := range []struct {
+		header string
+		code   int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Bearer s3cret", http.StatusOK},
+	} {
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/herd", nil)
+		if err != nil {
+			t.Fatalf("failed to create a request")
+		}
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		handler.ServeHTTP(resp, req)
+		if resp.Code != tc.code {
+			t.Errorf("handler returned wrong status code for %q: got %v want %v",
+				tc.header, resp.Code, tc.code)
+		}
+	}
+}
+
+func TestHandlerServesMetrics(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	resp := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	if err != nil {
+		t.Fatalf("failed to create a request")
+	}
+
+	gf.Handler().ServeHTTP(resp, req)
+	if status := resp.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusOK)
+	}
+}
+
+func TestWorkInflightGet(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	gf.registry.queued("abc")
+	gf.registry.started("abc", 1, 4, func() {})
+
+	resp := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/work/inflight", nil)
+	if err != nil {
+		t.Fatalf("failed to create a request")
+	}
+	gf.Handler().ServeHTTP(resp, req)
+
+	var inFlight []InFlightWork
+	if err := json.Unmarshal(resp.Body.Bytes(), &inFlight); err != nil {
+		t.Fatalf("could not decode response: %s", err)
+	}
+	if len(inFlight) != 1 || inFlight[0].ID != "abc" || inFlight[0].GopherID != 4 || inFlight[0].Attempt != 1 {
+		t.Errorf("handler returned unexpected body: got %v", resp.Body.String())
+	}
+}
+
+func TestWorkRetryingGet(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	gf.registry.queued("abc")
+	gf.registry.retrying("abc", func() {})
+
+	resp := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/work/retrying", nil)
+	if err != nil {
+		t.Fatalf("failed to create a request")
+	}
+	gf.Handler().ServeHTTP(resp, req)
+
+	expected := `[{"id":"abc","state":"retrying","attempts":[]}]`
+	if resp.Body.String() != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v",
+			resp.Body.String(), expected)
+	}
+}
+
+func TestWorkByIDGet(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	gf.registry.queued("abc")
+
+	resp := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/work/abc", nil)
+	if err != nil {
+		t.Fatalf("failed to create a request")
+	}
+	gf.Handler().ServeHTTP(resp, req)
+
+	expected := `{"id":"abc","state":"queued","attempts":[]}`
+	if resp.Code != http.StatusOK || resp.Body.String() != expected {
+		t.Errorf("handler returned unexpected response: got %v %v want %v",
+			resp.Code, resp.Body.String(), expected)
+	}
+
+	resp = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/work/missing", nil)
+	if err != nil {
+		t.Fatalf("failed to create a request")
+	}
+	gf.Handler().ServeHTTP(resp, req)
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			resp.Code, http.StatusNotFound)
+	}
+}
+
+func TestWorkDelete(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	gf.registry.queued("abc")
+
+	resp := httptest.NewRecorder()
+	req, err := http.NewRequest("DELETE", "/work/abc", nil)
+	if err != nil {
+		t.Fatalf("failed to create a request")
+	}
+	gf.Handler().ServeHTTP(resp, req)
+
+	expected := `{"msg":"cancelled"}`
+	if resp.Code != http.StatusOK || resp.Body.String() != expected {
+		t.Errorf("handler returned unexpected response: got %v %v want %v",
+			resp.Code, resp.Body.String(), expected)
+	}
+	if !gf.registry.isCancelled("abc") {
+		t.Errorf("expected work to be cancelled")
+	}
+
+	gf.registry.done("abc", Cancelled)
+	resp = httptest.NewRecorder()
+	gf.Handler().ServeHTTP(resp, req)
+	if resp.Code != http.StatusConflict {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			resp.Code, http.StatusConflict)
+	}
+}
+
+func TestConfigPatch(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+
+	resp := httptest.NewRecorder()
+	reader := bytes.NewReader([]byte(`{"max_retries": 5, "retry_backoff": "1s", "rate_limit": 2.5}`))
+	req, err := http.NewRequest("PATCH", "/config", reader)
+	if err != nil {
+		t.Fatalf("failed to create a request")
+	}
+	gf.Handler().ServeHTTP(resp, req)
+
+	expected := `{"max_retries":5,"retry_backoff":"1s","rate_limit":2.5,"work_timeout":"0s","msg":"success"}`
+	if resp.Code != http.StatusOK || resp.Body.String() != expected {
+		t.Errorf("handler returned unexpected response: got %v %v want %v",
+			resp.Code, resp.Body.String(), expected)
+	}
+
+	for _, body := range []string{`{"max_retries": -1}`, `{"work_timeout": "soon"}`, `not json`} {
+		resp = httptest.NewRecorder()
+		req, err = http.NewRequest("PATCH", "/config", bytes.NewReader([]byte(body)))
+		if err != nil {
+			t.Fatalf("failed to create a request")
+		}
+		gf.Handler().ServeHTTP(resp, req)
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("handler returned wrong status code for %s: got %v want %v",
+				body, resp.Code, http.StatusBadRequest)
+		}
+	}
+	if gf.Config().MaxRetries != 5 {
+		t.Errorf("expected rejected updates to not be applied")
+	}
+}
+
+func TestGophersGet(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	gf.gophers.add(1)
+	gf.gophers.add(2)
+	gf.gophers.busy(2)
+
+	resp := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/debug/gophers", nil)
+	if err != nil {
+		t.Fatalf("failed to create a request")
+	}
+	gf.Handler().ServeHTTP(resp, req)
+
+	var stats []GopherStats
+	if err := json.Unmarshal(resp.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("could not decode response: %s", err)
+	}
+	if len(stats) != 2 || stats[0].ID != 1 || stats[0].Busy || !stats[1].Busy {
+		t.Errorf("handler returned unexpected body: got %v", resp.Body.String())
+	}
+}
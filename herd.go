@@ -0,0 +1,60 @@
+package gofherd
+
+import "sync/atomic"
+
+// HerdSize is the requested number of gophers and the number currently running.
+// Running includes retiring gophers finishing their current Work, and drops to 0
+// once processing is complete.
+type HerdSize struct {
+	Target  int64
+	Running int64
+}
+
+// HerdSize returns the requested and running herd sizes.
+func (gf *Gofherd) HerdSize() HerdSize {
+	gf.herdMu.Lock()
+	defer gf.herdMu.Unlock()
+	return HerdSize{Target: gf.herdSize, Running: gf.gophers.running()}
+}
+
+// resize sets the target herd size to the one computed from the current target and
+// reconciles the running gophers with it. It never blocks on gophers: new ones are
+// spawned and retiring ones are signalled. It returns whether the target changed.
+func (gf *Gofherd) resize(target func(int64) int64) (HerdSize, bool) {
+	gf.herdMu.Lock()
+	oldSize := gf.herdSize
+	newSize := target(oldSize)
+	gf.herdSize = newSize
+	if gf.started {
+		gf.reconcileHerd()
+	}
+	size := HerdSize{Target: newSize, Running: gf.gophers.running()}
+	gf.herdMu.Unlock()
+
+	if oldSize == newSize {
+		gf.log.info("herd size unchanged", Field{"size", newSize})
+		return size, false
+	}
+	gf.log.info("updated herd size", Field{"from", oldSize}, Field{"to", newSize})
+	gf.events.publish(Event{Type: EventHerdResize, HerdSize: newSize})
+	gf.runHooks(nil, func(h EventHook, _ *Work) { h.OnHerdResize(oldSize, newSize) })
+	return size, true
+}
+
+// reconcileHerd spawns or retires gophers so that the active ones match the target.
+// No gophers are spawned once processing is complete. It must be called with herdMu held.
+func (gf *Gofherd) reconcileHerd() {
+	active := gf.gophers.active()
+	if active > gf.herdSize {
+		gf.gophers.retire(active - gf.herdSize)
+		return
+	}
+	if gf.output.closed() {
+		return
+	}
+	for i := active; i < gf.herdSize; i++ {
+		id := atomic.AddInt64(&(gf.gopherSeq), 1)
+		gf.log.debug("starting gopher", Field{"gopher_id", id})
+		go gf.initGopher(id, gf.gophers.add(id))
+	}
+}
@@ -0,0 +1,149 @@
+package gofherd
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func waitForRunning(gf *Gofherd, running int64, t *testing.T) {
+	deadline := time.Now().Add(5 * time.Second)
+	for gf.HerdSize().Running != running {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d running gophers, got: %+v", running, gf.HerdSize())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGopherdConcurrentResize(t *testing.T) {
+	workUnits := 1000
+	gf := getBasicGopherd(0, workUnits, 2, Success)
+	if err := gf.Start(); err != nil {
+		t.Fatalf("could not start gofherd: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				switch j % 4 {
+				case 0:
+					gf.updateHerdSize(int64(i))
+				case 1:
+					gf.IncreasedHerdBy(2)
+				case 2:
+					gf.DecreaseHerdBy(3)
+				default:
+					gf.HerdSize()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	gf.SetHerdSize(4)
+	if size := gf.HerdSize(); size.Target != 4 {
+		t.Fatalf("expected target herd size 4, got: %+v", size)
+	}
+
+	processed := 0
+	for range gf.OutputChan() {
+		processed++
+	}
+	if processed != workUnits {
+		t.Fatalf("expected %d processed work units, got: %d", workUnits, processed)
+	}
+	waitForRunning(gf, 0, t)
+}
+
+func TestGopherdResizeIsIdempotent(t *testing.T) {
+	gf := getBasicGopherd(0, 0, 3, Success)
+	if size, changed := gf.resize(func(int64) int64 { return 3 }); changed || size.Target != 3 {
+		t.Fatalf("expected unchanged target herd size 3, got: %+v, changed: %v", size, changed)
+	}
+	if size, changed := gf.resize(func(int64) int64 { return 5 }); !changed || size.Target != 5 || size.Running != 0 {
+		t.Fatalf("expected target herd size 5 with no gophers before start, got: %+v, changed: %v", size, changed)
+	}
+	gf.DecreaseHerdBy(10)
+	if size := gf.HerdSize(); size.Target != 0 {
+		t.Fatalf("expected herd size to stop at 0, got: %+v", size)
+	}
+}
+
+func TestGopherdShrinkAfterGophersExit(t *testing.T) {
+	gf := getBasicGopherd(0, 10, 3, Success)
+	if err := gf.Start(); err != nil {
+		t.Fatalf("could not start gofherd: %s", err)
+	}
+	for range gf.OutputChan() {
+	}
+	waitForRunning(gf, 0, t)
+
+	done := make(chan struct{})
+	go func() {
+		gf.DecreaseHerdBy(3)
+		gf.updateHerdSize(5)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("resizing the herd after the gophers exited did not return")
+	}
+	if size := gf.HerdSize(); size.Target != 5 || size.Running != 0 {
+		t.Fatalf("expected no gophers spawned once output is closed, got: %+v", size)
+	}
+}
+
+func TestGopherdBusyGopherFinishesBeforeRetiring(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	gf := New(func(w *Work) Status {
+		if w.ID == "busy" {
+			close(started)
+			<-release
+		}
+		return Success
+	})
+	gf.SetHerdSize(1)
+	gf.SetAddr("127.0.0.1:0")
+	if err := gf.Start(); err != nil {
+		t.Fatalf("could not start gofherd: %s", err)
+	}
+	gf.SendWork(Work{ID: "busy"})
+	<-started
+
+	status, msg := gf.updateHerdSize(0)
+	if status != Success || msg != "success" {
+		t.Fatalf("expected resize to 0 to succeed without blocking, got: %s, %s", status, msg)
+	}
+	if size := gf.HerdSize(); size.Target != 0 || size.Running != 1 {
+		t.Fatalf("expected the busy gopher to keep running, got: %+v", size)
+	}
+
+	close(release)
+	work := <-gf.OutputChan()
+	if work.ID != "busy" || work.Status() != Success {
+		t.Fatalf("expected busy work to finish with success, got: %s, %s", work.ID, work.Status())
+	}
+	waitForRunning(gf, 0, t)
+
+	gf.SetHerdSize(2)
+	go func() {
+		for i := 0; i < 5; i++ {
+			gf.SendWork(Work{ID: fmt.Sprintf("%d", i)})
+		}
+		gf.CloseInputChan()
+	}()
+	processed := 0
+	for range gf.OutputChan() {
+		processed++
+	}
+	if processed != 5 {
+		t.Fatalf("expected 5 processed work units after growing the herd, got: %d", processed)
+	}
+	assertAllChannelsClosed(gf, t)
+}
@@ -0,0 +1,77 @@
+package gofherd
+
+// EventHook interface is accepted by AddHook and is notified of lifecycle events.
+//
+// Hooks and callbacks are called synchronously, in the order they were added.
+// OnEnqueue runs on the goroutine calling SendWork, OnHerdResize on the goroutine
+// resizing the herd, and the others on the gopher processing the Work, before the Work
+// is pushed to the retry path or the output chan. A slow hook therefore blocks that gopher,
+// unless SetAsyncHooks is used to run hooks on a separate pool of workers.
+// Hooks are called concurrently from different gophers and must be concurrent safe.
+type EventHook interface {
+	OnEnqueue(w *Work)
+	OnStart(w *Work)
+	OnRetry(w *Work)
+	OnSuccess(w *Work)
+	OnFailure(w *Work)
+	OnCancelled(w *Work)
+	OnHerdResize(from, to int64)
+}
+
+// BaseHook implements EventHook with no-ops. It can be embedded to implement only some of the methods.
+type BaseHook struct {
+}
+
+// OnEnqueue is called when Work is sent with SendWork.
+func (BaseHook) OnEnqueue(w *Work) {}
+
+// OnStart is called before each attempt of the processing logic.
+func (BaseHook) OnStart(w *Work) {}
+
+// OnRetry is called when the processing logic returns Retry and the Work will be retried.
+func (BaseHook) OnRetry(w *Work) {}
+
+// OnSuccess is called when the Work reaches the Success status.
+func (BaseHook) OnSuccess(w *Work) {}
+
+// OnFailure is called when the Work reaches the Failure status.
+func (BaseHook) OnFailure(w *Work) {}
+
+// OnCancelled is called when the Work reaches the Cancelled status.
+func (BaseHook) OnCancelled(w *Work) {}
+
+// OnHerdResize is called when the herd size is changed.
+func (BaseHook) OnHerdResize(from, to int64) {}
+
+// callbackHook adapts the callbacks added with Add*Callback to an EventHook.
+type callbackHook struct {
+	BaseHook
+	success   func(*Work)
+	retry     func(*Work)
+	failure   func(*Work)
+	cancelled func(*Work)
+}
+
+func (c callbackHook) OnSuccess(w *Work) {
+	if c.success != nil {
+		c.success(w)
+	}
+}
+
+func (c callbackHook) OnRetry(w *Work) {
+	if c.retry != nil {
+		c.retry(w)
+	}
+}
+
+func (c callbackHook) OnFailure(w *Work) {
+	if c.failure != nil {
+		c.failure(w)
+	}
+}
+
+func (c callbackHook) OnCancelled(w *Work) {
+	if c.cancelled != nil {
+		c.cancelled(w)
+	}
+}
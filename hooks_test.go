@@ -0,0 +1,96 @@
+package gofherd
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingHook struct {
+	BaseHook
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (c *countingHook) inc(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[name]++
+}
+
+func (c *countingHook) OnEnqueue(w *Work)           { c.inc("enqueue") }
+func (c *countingHook) OnStart(w *Work)             { c.inc("start") }
+func (c *countingHook) OnRetry(w *Work)             { c.inc("retry") }
+func (c *countingHook) OnFailure(w *Work)           { c.inc("failure") }
+func (c *countingHook) OnCancelled(w *Work)         { c.inc("cancelled") }
+func (c *countingHook) OnHerdResize(from, to int64) { c.inc("resize") }
+
+func TestHooksAreCalledForEachEvent(t *testing.T) {
+	maxRetries := 2
+	workUnits := 3
+	gofherdSize := 1
+	gf := getBasicGopherd(maxRetries, workUnits, gofherdSize, Retry)
+	hook := &countingHook{counts: make(map[string]int)}
+	gf.AddHook(hook)
+	gf.Start()
+	gf.updateHerdSize(2)
+
+	for range gf.OutputChan() {
+	}
+	expected := map[string]int{
+		"enqueue": workUnits,
+		"start":   workUnits * (maxRetries + 1),
+		"retry":   workUnits * maxRetries,
+		"failure": workUnits,
+		"resize":  1,
+	}
+	for name, count := range expected {
+		if hook.counts[name] != count {
+			t.Fatalf("did not get expected %s count, expected: %d, got: %d", name, count, hook.counts[name])
+		}
+	}
+	assertAllChannelsClosed(gf, t)
+}
+
+func TestMultipleCallbacksAreCalled(t *testing.T) {
+	workUnits := 5
+	gf := getBasicGopherd(0, workUnits, 2, Success)
+	first, second := int64(0), int64(0)
+	gf.AddSuccessCallback(func(w *Work) { atomic.AddInt64(&first, 1) })
+	gf.AddSuccessCallback(func(w *Work) { atomic.AddInt64(&second, 1) })
+	gf.Start()
+
+	for range gf.OutputChan() {
+	}
+	if first != int64(workUnits) || second != int64(workUnits) {
+		t.Fatalf("expected both callbacks to be called %d times, got: %d and %d", workUnits, first, second)
+	}
+	assertAllChannelsClosed(gf, t)
+}
+
+func TestCancelledHooksAreCalled(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	gf.SetHerdSize(1)
+	gf.SetAddr("127.0.0.1:0")
+	hook := &countingHook{counts: make(map[string]int)}
+	gf.AddHook(hook)
+	cancelled := int64(0)
+	gf.AddCancelledCallback(func(w *Work) { atomic.AddInt64(&cancelled, 1) })
+	go func() {
+		gf.SendWork(Work{ID: "a"})
+		gf.CloseInputChan()
+	}()
+	for _, ok := gf.WorkInfo("a"); !ok; _, ok = gf.WorkInfo("a") {
+		time.Sleep(time.Millisecond)
+	}
+	if err := gf.Cancel("a"); err != nil {
+		t.Fatalf("could not cancel: %s", err)
+	}
+	gf.Start()
+	for range gf.OutputChan() {
+	}
+	if hook.counts["cancelled"] != 1 || atomic.LoadInt64(&cancelled) != 1 {
+		t.Fatalf("expected the cancelled hook and callback to be called once, got: %d and %d", hook.counts["cancelled"], cancelled)
+	}
+}
@@ -1,5 +1,12 @@
 package gofherd
 
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
 // Logger interface is accepted by SetLogger function and used to log the output.
 // It has a single function Printf with the signature: `Printf(format string, v ...interface{})`
 type Logger interface {
@@ -11,3 +18,121 @@ type noOpLogger struct {
 
 func (noop noOpLogger) Printf(format string, v ...interface{}) {
 }
+
+// Level is the severity of a log entry.
+type Level int
+
+const (
+	// LevelDebug is for per Work unit events like enqueue and dequeue.
+	LevelDebug Level = iota
+	// LevelInfo is for herd wide events like start, resize and completion.
+	LevelInfo
+	// LevelWarn is for rejected requests and Work units giving up after retries.
+	LevelWarn
+	// LevelError is for errors which gofherd cannot recover from.
+	LevelError
+)
+
+var levelStrings = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+func (l Level) String() string {
+	if val, ok := levelStrings[l]; ok {
+		return val
+	}
+	return "unknown"
+}
+
+// Field is a key value pair attached to a log entry, like the Work ID or the gopher ID.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// StructuredLogger interface is accepted by SetStructuredLogger and receives
+// every log entry at or above the configured minimum level.
+type StructuredLogger interface {
+	Log(level Level, msg string, fields ...Field)
+}
+
+type printfLogger struct {
+	logger Logger
+}
+
+// NewPrintfLogger wraps a Printf style Logger as a StructuredLogger.
+// Entries are formatted as `level msg key=value ...`.
+func NewPrintfLogger(l Logger) StructuredLogger {
+	return printfLogger{logger: l}
+}
+
+func (p printfLogger) Log(level Level, msg string, fields ...Field) {
+	var b strings.Builder
+	b.WriteString(level.String())
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for _, f := range fields {
+		fmt.Fprintf(&b, " %s=%v", f.Key, f.Value)
+	}
+	p.logger.Printf("%s\n", b.String())
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger wraps a `log/slog` Logger as a StructuredLogger.
+func NewSlogLogger(l *slog.Logger) StructuredLogger {
+	return slogLogger{logger: l}
+}
+
+var slogLevels = map[Level]slog.Level{
+	LevelDebug: slog.LevelDebug,
+	LevelInfo:  slog.LevelInfo,
+	LevelWarn:  slog.LevelWarn,
+	LevelError: slog.LevelError,
+}
+
+func (s slogLogger) Log(level Level, msg string, fields ...Field) {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, f := range fields {
+		attrs = append(attrs, slog.Any(f.Key, f.Value))
+	}
+	s.logger.LogAttrs(context.Background(), slogLevels[level], msg, attrs...)
+}
+
+// leveledLogger drops entries below the minimum level before they reach the StructuredLogger.
+type leveledLogger struct {
+	logger StructuredLogger
+	min    Level
+}
+
+func (l leveledLogger) log(level Level, msg string, fields ...Field) {
+	if level < l.min {
+		return
+	}
+	l.logger.Log(level, msg, fields...)
+}
+
+func (l leveledLogger) debug(msg string, fields ...Field) {
+	l.log(LevelDebug, msg, fields...)
+}
+
+func (l leveledLogger) info(msg string, fields ...Field) {
+	l.log(LevelInfo, msg, fields...)
+}
+
+func (l leveledLogger) warn(msg string, fields ...Field) {
+	l.log(LevelWarn, msg, fields...)
+}
+
+func (l leveledLogger) error(msg string, fields ...Field) {
+	l.log(LevelError, msg, fields...)
+}
+
+func workFields(work *Work) []Field {
+	return []Field{{"work_id", work.ID}, {"attempt", work.retryCount() + 1}, {"status", work.Status()}}
+}
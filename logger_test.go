@@ -0,0 +1,62 @@
+package gofherd
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type bufferLogger struct {
+	buf bytes.Buffer
+}
+
+func (b *bufferLogger) Printf(format string, v ...interface{}) {
+	fmt.Fprintf(&b.buf, format, v...)
+}
+
+func TestPrintfLogger(t *testing.T) {
+	b := &bufferLogger{}
+	l := NewPrintfLogger(b)
+	l.Log(LevelWarn, "work exhausted retries", Field{"work_id", "abc"}, Field{"attempt", 3})
+
+	expected := "warn work exhausted retries work_id=abc attempt=3\n"
+	if b.buf.String() != expected {
+		t.Fatalf("did not get expected log line, expected: %q, got: %q", expected, b.buf.String())
+	}
+}
+
+func TestLeveledLoggerDropsBelowMinimum(t *testing.T) {
+	b := &bufferLogger{}
+	l := leveledLogger{logger: NewPrintfLogger(b), min: LevelInfo}
+	l.debug("pushed work to input", Field{"work_id", "abc"})
+	if b.buf.Len() != 0 {
+		t.Fatalf("expected debug entry to be dropped, got: %q", b.buf.String())
+	}
+
+	l.info("closed input chan")
+	if b.buf.String() != "info closed input chan\n" {
+		t.Fatalf("expected info entry to be logged, got: %q", b.buf.String())
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	l := NewSlogLogger(slog.New(handler))
+	l.Log(LevelDebug, "received work from input", Field{"work_id", "abc"}, Field{"gopher_id", int64(2)})
+
+	line := buf.String()
+	for _, expected := range []string{"level=DEBUG", `msg="received work from input"`, "work_id=abc", "gopher_id=2"} {
+		if !strings.Contains(line, expected) {
+			t.Fatalf("expected %q in log line, got: %q", expected, line)
+		}
+	}
+}
+
+func TestLevelString(t *testing.T) {
+	if LevelError.String() != "error" || Level(42).String() != "unknown" {
+		t.Fatalf("did not get expected level strings, got: %s, %s", LevelError, Level(42))
+	}
+}
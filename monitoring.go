@@ -1,33 +1,134 @@
 package gofherd
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-var (
-	successMetric = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "gofherd_success_total",
-		Help: "The total number of success events",
-	})
-	failureMetric = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "gofherd_failure_total",
-		Help: "The total number of failure events",
-	})
-	retryMetric = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "gofherd_retry_total",
-		Help: "The total number of retry events",
-	})
-)
+// metrics are the collectors a Gofherd reports to. By default all of them
+// report to defaultMetrics, registered with the default prometheus registry.
+type metrics struct {
+	success         prometheus.Counter
+	failure         prometheus.Counter
+	retry           prometheus.Counter
+	cancelled       prometheus.Counter
+	eventsDropped   prometheus.Counter
+	callbackDropped prometheus.Counter
+	callbackLatency prometheus.Histogram
+	utilization     prometheus.Gauge
+	reorderHeld     prometheus.Gauge
+	scheduled       prometheus.Gauge
+	gatherer        prometheus.Gatherer
+}
+
+var defaultMetrics = newMetrics(prometheus.DefaultRegisterer)
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	factory := promauto.With(reg)
+	m := &metrics{
+		success: factory.NewCounter(prometheus.CounterOpts{
+			Name: "gofherd_success_total",
+			Help: "The total number of success events",
+		}),
+		failure: factory.NewCounter(prometheus.CounterOpts{
+			Name: "gofherd_failure_total",
+			Help: "The total number of failure events",
+		}),
+		retry: factory.NewCounter(prometheus.CounterOpts{
+			Name: "gofherd_retry_total",
+			Help: "The total number of retry events",
+		}),
+		cancelled: factory.NewCounter(prometheus.CounterOpts{
+			Name: "gofherd_cancelled_total",
+			Help: "The total number of cancelled events",
+		}),
+		eventsDropped: factory.NewCounter(prometheus.CounterOpts{
+			Name: "gofherd_events_dropped_total",
+			Help: "The total number of lifecycle events dropped for slow subscribers",
+		}),
+		callbackDropped: factory.NewCounter(prometheus.CounterOpts{
+			Name: "gofherd_callback_dropped_total",
+			Help: "The total number of hook and callback calls dropped by the async dispatcher",
+		}),
+		callbackLatency: factory.NewHistogram(prometheus.HistogramOpts{
+			Name:    "gofherd_callback_duration_seconds",
+			Help:    "The time taken by hook and callback calls",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}),
+		utilization: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "gofherd_herd_utilization",
+			Help: "The fraction of time the running gophers have spent processing work",
+		}),
+		reorderHeld: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "gofherd_reorder_held",
+			Help: "The number of completed work units held back to preserve ordering",
+		}),
+		scheduled: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "gofherd_scheduled",
+			Help: "The number of work units sent with SendWorkAt or SendWorkAfter which are not yet due",
+		}),
+	}
+	if g, ok := reg.(prometheus.Gatherer); ok && reg != prometheus.DefaultRegisterer {
+		m.gatherer = g
+	}
+	return m
+}
+
+// SetMetricsRegistry makes the herd report its metrics to reg instead of the default
+// prometheus registry, so that several herds, or tests, do not share counters.
+// If reg is also a prometheus.Gatherer, `/metrics` serves it. It must be called before Start.
+func (gf *Gofherd) SetMetricsRegistry(reg prometheus.Registerer) {
+	*gf.metrics = *newMetrics(reg)
+}
+
+// handler serves the metrics, from the default prometheus registry unless a gatherer is set.
+func (m *metrics) handler() http.Handler {
+	if m.gatherer != nil {
+		return promhttp.HandlerFor(m.gatherer, promhttp.HandlerOpts{})
+	}
+	return promhttp.Handler()
+}
+
+func (m *metrics) incrementSuccess() {
+	m.success.Inc()
+}
+
+func (m *metrics) incrementRetry() {
+	m.retry.Inc()
+}
+
+func (m *metrics) incrementFailure() {
+	m.failure.Inc()
+}
+
+func (m *metrics) incrementCancelled() {
+	m.cancelled.Inc()
+}
+
+func (m *metrics) incrementEventsDropped() {
+	m.eventsDropped.Inc()
+}
+
+func (m *metrics) incrementCallbackDropped() {
+	m.callbackDropped.Inc()
+}
+
+func (m *metrics) observeCallbackLatency(d time.Duration) {
+	m.callbackLatency.Observe(d.Seconds())
+}
 
-func incrementSuccessMetric() {
-	successMetric.Inc()
+func (m *metrics) setUtilization(utilization float64) {
+	m.utilization.Set(utilization)
 }
 
-func incrementRetryMetric() {
-	retryMetric.Inc()
+func (m *metrics) setReorderHeld(num int) {
+	m.reorderHeld.Set(float64(num))
 }
 
-func incrementFailureMetric() {
-	failureMetric.Inc()
+func (m *metrics) addScheduled(num int) {
+	m.scheduled.Add(float64(num))
 }
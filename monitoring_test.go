@@ -0,0 +1,37 @@
+package gofherd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSetMetricsRegistryIsolatesHerds(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	gf := getBasicGopherd(0, 3, 1, Success)
+	gf.SetMetricsRegistry(reg)
+	other := New(func(w *Work) Status { return Success })
+
+	oldDefault := testutil.ToFloat64(other.metrics.success)
+	gf.Start()
+	for range gf.OutputChan() {
+	}
+
+	if success := testutil.ToFloat64(gf.metrics.success); success != 3 {
+		t.Fatalf("expected 3 successes in the herd registry, got: %f", success)
+	}
+	if success := testutil.ToFloat64(other.metrics.success); success != oldDefault {
+		t.Fatalf("expected default registry to be untouched, expected: %f, got: %f", oldDefault, success)
+	}
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	gf.Handler().ServeHTTP(resp, req)
+	if !strings.Contains(resp.Body.String(), "gofherd_success_total 3") {
+		t.Fatalf("expected /metrics to serve the herd registry, got: %s", resp.Body.String())
+	}
+}
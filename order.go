@@ -0,0 +1,74 @@
+package gofherd
+
+import "sync"
+
+// reorderBuffer holds completed Work until all Work sent before it has been
+// emitted, so that the output is in the same order as the input.
+type reorderBuffer struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	// emitMu keeps the Work collected by two gophers in order, without holding mu while
+	// waiting on the output chan
+	emitMu  sync.Mutex
+	window  uint64
+	seq     uint64
+	next    uint64
+	held    map[uint64]Work
+	metrics *metrics
+}
+
+func newReorderBuffer(window int64, m *metrics) *reorderBuffer {
+	rb := &reorderBuffer{held: make(map[uint64]Work), metrics: m}
+	if window > 0 {
+		rb.window = uint64(window)
+	}
+	rb.cond = sync.NewCond(&rb.mu)
+	return rb
+}
+
+// assign returns the next sequence number. If the window is bounded, it blocks
+// until the sequence number is within the window of the next one to be emitted.
+func (rb *reorderBuffer) assign() uint64 {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	for rb.window > 0 && rb.seq >= rb.next+rb.window {
+		rb.cond.Wait()
+	}
+	seq := rb.seq
+	rb.seq++
+	return seq
+}
+
+// push adds completed Work to the buffer and emits all Work that is now in order.
+// Emitting happens under the emit lock so that two gophers cannot interleave their output.
+func (rb *reorderBuffer) push(work Work, emit func(Work)) {
+	rb.mu.Lock()
+	rb.held[work.seq] = work
+	rb.mu.Unlock()
+
+	rb.emitMu.Lock()
+	defer rb.emitMu.Unlock()
+	rb.mu.Lock()
+	var ready []Work
+	for {
+		w, ok := rb.held[rb.next]
+		if !ok {
+			break
+		}
+		delete(rb.held, rb.next)
+		rb.next++
+		ready = append(ready, w)
+	}
+	rb.metrics.setReorderHeld(len(rb.held))
+	rb.cond.Broadcast()
+	rb.mu.Unlock()
+	for _, w := range ready {
+		emit(w)
+	}
+}
+
+func (rb *reorderBuffer) count() int {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return len(rb.held)
+}
@@ -0,0 +1,77 @@
+package gofherd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReorderBufferEmitsInOrder(t *testing.T) {
+	rb := newReorderBuffer(0, defaultMetrics)
+	var emitted []uint64
+	emit := func(w Work) { emitted = append(emitted, w.seq) }
+
+	for i := 0; i < 3; i++ {
+		rb.assign()
+	}
+	rb.push(Work{seq: 2}, emit)
+	rb.push(Work{seq: 1}, emit)
+	if len(emitted) != 0 || rb.count() != 2 {
+		t.Fatalf("expected work to be held back, emitted: %v, held: %d", emitted, rb.count())
+	}
+	rb.push(Work{seq: 0}, emit)
+	if len(emitted) != 3 || emitted[0] != 0 || emitted[1] != 1 || emitted[2] != 2 {
+		t.Fatalf("did not emit in order, got: %v", emitted)
+	}
+	if rb.count() != 0 {
+		t.Fatalf("expected no held work, got: %d", rb.count())
+	}
+}
+
+func TestReorderBufferWindowBlocksAssign(t *testing.T) {
+	rb := newReorderBuffer(2, defaultMetrics)
+	rb.assign()
+	rb.assign()
+
+	assigned := make(chan uint64)
+	go func() { assigned <- rb.assign() }()
+
+	select {
+	case seq := <-assigned:
+		t.Fatalf("expected assign to block on full window, got seq: %d", seq)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	rb.push(Work{seq: 0}, func(Work) {})
+	select {
+	case seq := <-assigned:
+		if seq != 2 {
+			t.Fatalf("expected seq 2, got: %d", seq)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("assign did not unblock after the window advanced")
+	}
+}
+
+func TestReorderBufferEmitDoesNotBlockAssign(t *testing.T) {
+	rb := newReorderBuffer(0, defaultMetrics)
+	rb.assign()
+	release := make(chan struct{})
+	go rb.push(Work{seq: 0}, func(Work) { <-release })
+	defer close(release)
+
+	// a slow consumer of the output holds the emitting gopher, not the senders
+	deadline := time.Now().Add(5 * time.Second)
+	for rb.count() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assigned := make(chan uint64)
+	go func() { assigned <- rb.assign() }()
+	select {
+	case seq := <-assigned:
+		if seq != 1 {
+			t.Fatalf("expected seq 1, got: %d", seq)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected assign not to wait for the output chan")
+	}
+}
@@ -0,0 +1,160 @@
+package gofherd
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressBuckets is the number of one second buckets kept for throughput, enough for 15 minutes.
+const progressBuckets = 900
+
+// Throughput is the number of Work units completed per second over recent windows.
+type Throughput struct {
+	Last1m  float64 `json:"1m"`
+	Last5m  float64 `json:"5m"`
+	Last15m float64 `json:"15m"`
+}
+
+// Progress is a snapshot of how far along the herd is.
+// ETA is only set when the expected total is known via SetExpectedTotal
+// and there is some recent throughput to extrapolate from.
+type Progress struct {
+	Submitted      uint64
+	Expected       uint64
+	Success        uint64
+	Failure        uint64
+	Cancelled      uint64
+	Retries        uint64
+	InFlight       int64
+	PendingRetries int64
+	Throughput     Throughput
+	ETA            time.Duration
+}
+
+// Completed is the number of Work units which have reached a final status.
+func (p Progress) Completed() uint64 {
+	return p.Success + p.Failure + p.Cancelled
+}
+
+type progressTracker struct {
+	mu             sync.Mutex
+	now            func() time.Time
+	start          time.Time
+	buckets        [progressBuckets]uint64
+	bucketSecs     [progressBuckets]int64
+	expected       uint64
+	success        uint64
+	failure        uint64
+	cancelled      uint64
+	retries        uint64
+	inFlight       int64
+	pendingRetries int64
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{now: time.Now, start: time.Now()}
+}
+
+func (p *progressTracker) markStarted() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.start = p.now()
+}
+
+func (p *progressTracker) setExpected(num uint64) {
+	atomic.StoreUint64(&(p.expected), num)
+}
+
+func (p *progressTracker) startProcessing() {
+	atomic.AddInt64(&(p.inFlight), 1)
+}
+
+func (p *progressTracker) doneProcessing() {
+	atomic.AddInt64(&(p.inFlight), -1)
+}
+
+func (p *progressTracker) retryPending() {
+	atomic.AddUint64(&(p.retries), 1)
+	atomic.AddInt64(&(p.pendingRetries), 1)
+}
+
+func (p *progressTracker) retryPicked() {
+	atomic.AddInt64(&(p.pendingRetries), -1)
+}
+
+func (p *progressTracker) completed(status Status) {
+	switch status {
+	case Success:
+		atomic.AddUint64(&(p.success), 1)
+	case Cancelled:
+		atomic.AddUint64(&(p.cancelled), 1)
+	default:
+		atomic.AddUint64(&(p.failure), 1)
+	}
+	sec := p.now().Unix()
+	idx := sec % progressBuckets
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.bucketSecs[idx] != sec {
+		p.bucketSecs[idx] = sec
+		p.buckets[idx] = 0
+	}
+	p.buckets[idx]++
+}
+
+// rate returns the completions per second over the last `window` seconds,
+// or since start if the herd has been running for less than that.
+func (p *progressTracker) rate(now time.Time, window int64) float64 {
+	sec := now.Unix()
+	var total uint64
+	for i := int64(0); i < window; i++ {
+		idx := (sec - i) % progressBuckets
+		if idx < 0 {
+			idx += progressBuckets
+		}
+		if p.bucketSecs[idx] == sec-i {
+			total += p.buckets[idx]
+		}
+	}
+	elapsed := now.Sub(p.start).Seconds()
+	if elapsed < 1 {
+		elapsed = 1
+	}
+	if elapsed > float64(window) {
+		elapsed = float64(window)
+	}
+	return float64(total) / elapsed
+}
+
+func (p *progressTracker) snapshot(submitted uint64) Progress {
+	now := p.now()
+	progress := Progress{
+		Submitted:      submitted,
+		Expected:       atomic.LoadUint64(&(p.expected)),
+		Success:        atomic.LoadUint64(&(p.success)),
+		Failure:        atomic.LoadUint64(&(p.failure)),
+		Cancelled:      atomic.LoadUint64(&(p.cancelled)),
+		Retries:        atomic.LoadUint64(&(p.retries)),
+		InFlight:       atomic.LoadInt64(&(p.inFlight)),
+		PendingRetries: atomic.LoadInt64(&(p.pendingRetries)),
+	}
+	p.mu.Lock()
+	progress.Throughput = Throughput{
+		Last1m:  p.rate(now, 60),
+		Last5m:  p.rate(now, 300),
+		Last15m: p.rate(now, 900),
+	}
+	p.mu.Unlock()
+
+	if progress.Expected > progress.Completed() {
+		remaining := float64(progress.Expected - progress.Completed())
+		for _, rate := range []float64{progress.Throughput.Last1m, progress.Throughput.Last5m, progress.Throughput.Last15m} {
+			if rate > 0 {
+				progress.ETA = time.Duration(remaining / rate * float64(time.Second))
+				break
+			}
+		}
+	}
+	return progress
+}
@@ -0,0 +1,53 @@
+package gofherd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressTrackerThroughputAndETA(t *testing.T) {
+	now := time.Unix(1000, 0)
+	p := newProgressTracker()
+	p.now = func() time.Time { return now }
+	p.markStarted()
+	p.setExpected(100)
+
+	now = now.Add(10 * time.Second)
+	for i := 0; i < 20; i++ {
+		p.completed(Success)
+	}
+	for i := 0; i < 10; i++ {
+		p.completed(Failure)
+	}
+
+	snapshot := p.snapshot(40)
+	if snapshot.Submitted != 40 || snapshot.Success != 20 || snapshot.Failure != 10 || snapshot.Completed() != 30 {
+		t.Fatalf("did not get expected counts, got: %+v", snapshot)
+	}
+	if snapshot.Throughput.Last1m != 3 {
+		t.Fatalf("did not get expected throughput, expected: %f, got: %f", 3.0, snapshot.Throughput.Last1m)
+	}
+	expectedETA := 70 * time.Second / 3
+	if snapshot.ETA != expectedETA {
+		t.Fatalf("did not get expected eta, expected: %s, got: %s", expectedETA, snapshot.ETA)
+	}
+
+	now = now.Add(2 * time.Minute)
+	snapshot = p.snapshot(40)
+	if snapshot.Throughput.Last1m != 0 || snapshot.Throughput.Last5m != 30.0/130.0 {
+		t.Fatalf("did not get expected throughput after idling, got: %+v", snapshot.Throughput)
+	}
+}
+
+func TestProgressTrackerInFlightAndRetries(t *testing.T) {
+	p := newProgressTracker()
+	p.startProcessing()
+	p.startProcessing()
+	p.doneProcessing()
+	p.retryPending()
+
+	snapshot := p.snapshot(2)
+	if snapshot.InFlight != 1 || snapshot.PendingRetries != 1 || snapshot.ETA != 0 {
+		t.Fatalf("did not get expected progress, got: %+v", snapshot)
+	}
+}
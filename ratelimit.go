@@ -0,0 +1,60 @@
+package gofherd
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces out calls evenly so that at most `rate` calls start per second.
+// A rate of 0 is unlimited.
+type rateLimiter struct {
+	mu    sync.Mutex
+	rate  float64
+	next  time.Time
+	clock Clock
+}
+
+func newRateLimiter(clock Clock) *rateLimiter {
+	return &rateLimiter{clock: clock}
+}
+
+func (rl *rateLimiter) setRate(rate float64) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.rate = rate
+	rl.next = time.Time{}
+}
+
+func (rl *rateLimiter) getRate() float64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.rate
+}
+
+// wait blocks until the caller is allowed to proceed, or ctx is done.
+func (rl *rateLimiter) wait(ctx context.Context) error {
+	rl.mu.Lock()
+	if rl.rate <= 0 {
+		rl.mu.Unlock()
+		return nil
+	}
+	now := rl.clock.Now()
+	slot := rl.next
+	if slot.Before(now) {
+		slot = now
+	}
+	rl.next = slot.Add(time.Duration(float64(time.Second) / rl.rate))
+	rl.mu.Unlock()
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	select {
+	case <-rl.clock.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
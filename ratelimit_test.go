@@ -0,0 +1,45 @@
+package gofherd
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterSpacesCalls(t *testing.T) {
+	rl := newRateLimiter(realClock{})
+	rl.setRate(100)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := rl.wait(context.Background()); err != nil {
+			t.Fatalf("unexpected error from wait: %s", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("expected 5 calls at 100/s to take at least 40ms, took: %s", elapsed)
+	}
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	rl := newRateLimiter(realClock{})
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		rl.wait(context.Background())
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("expected unlimited rate to not wait, took: %s", elapsed)
+	}
+}
+
+func TestRateLimiterWaitIsCancelled(t *testing.T) {
+	rl := newRateLimiter(realClock{})
+	rl.setRate(0.1)
+	rl.wait(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := rl.wait(ctx); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+}
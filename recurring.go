@@ -0,0 +1,343 @@
+package gofherd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// OverlapPolicy decides what happens to a run of a Schedule which is due while
+// the previous run is not done yet.
+type OverlapPolicy int
+
+const (
+	// OverlapSkip drops the run. It is the default.
+	OverlapSkip OverlapPolicy = iota
+	// OverlapQueue holds the run until the previous ones are done, so that runs never overlap.
+	OverlapQueue
+	// OverlapAllow enqueues the run regardless.
+	OverlapAllow
+)
+
+var overlapNames = map[OverlapPolicy]string{
+	OverlapSkip:  "skip",
+	OverlapQueue: "queue",
+	OverlapAllow: "allow",
+}
+
+func (p OverlapPolicy) String() string {
+	return overlapNames[p]
+}
+
+// ErrScheduleExists is returned by AddSchedule for a name which is already registered.
+var ErrScheduleExists = errors.New("schedule already exists")
+
+// Schedule is recurring Work. Each run enqueues a Work unit with the Body, and an ID made
+// of the Name and the time of the run, like `probe@2024-01-02T15:04:00Z`.
+type Schedule struct {
+	// Name identifies the schedule, it must be unique in the herd.
+	Name string
+	// Cron is a cron expression with the five fields minute, hour, day of month, month
+	// and day of week, like `*/5 * * * *`, or a macro like `@hourly`.
+	Cron string
+	// Every runs the schedule at an interval from when it is added, instead of Cron.
+	Every time.Duration
+	// Location is the time zone Cron is evaluated in, it defaults to time.Local.
+	Location *time.Location
+	// Body is the Body of the Work of every run.
+	Body interface{}
+	// Overlap is what happens to a run due while the previous one is not done.
+	Overlap OverlapPolicy
+}
+
+// ScheduleInfo is the state of a Schedule, returned by Schedules and `GET /schedules`.
+type ScheduleInfo struct {
+	Name    string     `json:"name"`
+	Spec    string     `json:"spec"`
+	Overlap string     `json:"overlap"`
+	Next    time.Time  `json:"next"`
+	LastRun *time.Time `json:"last_run,omitempty"`
+	Runs    uint64     `json:"runs"`
+	Skipped uint64     `json:"skipped"`
+	Running int        `json:"running"`
+	Queued  int        `json:"queued"`
+}
+
+// intervalSchedule runs every interval.
+type intervalSchedule time.Duration
+
+func (is intervalSchedule) next(t time.Time) time.Time {
+	return t.Add(time.Duration(is))
+}
+
+// recurringSchedule is a registered Schedule and its runs.
+type recurringSchedule struct {
+	Schedule
+	spec    interface{ next(time.Time) time.Time }
+	next    time.Time
+	last    time.Time
+	runs    uint64
+	skipped uint64
+	running map[string]bool
+	queued  []time.Time
+}
+
+func (rs *recurringSchedule) info() ScheduleInfo {
+	info := ScheduleInfo{Name: rs.Name, Spec: rs.Cron, Overlap: rs.Overlap.String(), Next: rs.next,
+		Runs: rs.runs, Skipped: rs.skipped, Running: len(rs.running), Queued: len(rs.queued)}
+	if rs.Cron == "" {
+		info.Spec = "@every " + rs.Every.String()
+	}
+	if !rs.last.IsZero() {
+		last := rs.last
+		info.LastRun = &last
+	}
+	return info
+}
+
+// start records a run of the schedule, returning its Work.
+func (rs *recurringSchedule) start(run time.Time) Work {
+	id := fmt.Sprintf("%s@%s", rs.Name, run.UTC().Format(time.RFC3339Nano))
+	rs.running[id] = true
+	rs.runs++
+	rs.last = run
+	return Work{ID: id, Body: rs.Body}
+}
+
+// scheduleRun is a started run which is not done, with what is needed to undo its start.
+type scheduleRun struct {
+	schedule *recurringSchedule
+	at       time.Time
+	last     time.Time
+	queued   bool
+}
+
+// recurring holds the registered schedules, and which of their runs are not done.
+type recurring struct {
+	mu        sync.Mutex
+	schedules map[string]*recurringSchedule
+	runs      map[string]scheduleRun
+	clock     Clock
+	wake      chan struct{}
+}
+
+func newRecurring(clock Clock) *recurring {
+	return &recurring{
+		schedules: make(map[string]*recurringSchedule),
+		runs:      make(map[string]scheduleRun),
+		clock:     clock,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+func (r *recurring) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *recurring) add(s Schedule) error {
+	if s.Name == "" {
+		return errors.New("schedule without a name")
+	}
+	if s.Location == nil {
+		s.Location = time.Local
+	}
+	rs := &recurringSchedule{Schedule: s, running: make(map[string]bool)}
+	switch {
+	case s.Cron != "" && s.Every != 0:
+		return fmt.Errorf("schedule %s: set either Cron or Every", s.Name)
+	case s.Cron != "":
+		cs, err := parseCron(s.Cron, s.Location)
+		if err != nil {
+			return fmt.Errorf("schedule %s: %w", s.Name, err)
+		}
+		rs.spec = cs
+	case s.Every > 0:
+		rs.spec = intervalSchedule(s.Every)
+	default:
+		return fmt.Errorf("schedule %s: Cron or a positive Every is required", s.Name)
+	}
+	if _, ok := overlapNames[s.Overlap]; !ok {
+		return fmt.Errorf("schedule %s: invalid overlap policy %d", s.Name, s.Overlap)
+	}
+	rs.next = rs.spec.next(r.clock.Now())
+	if rs.next.IsZero() {
+		return fmt.Errorf("schedule %s: cron expression %q never runs", s.Name, s.Cron)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.schedules[s.Name]; ok {
+		return fmt.Errorf("schedule %s: %w", s.Name, ErrScheduleExists)
+	}
+	r.schedules[s.Name] = rs
+	r.notify()
+	return nil
+}
+
+func (r *recurring) remove(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.schedules[name]
+	delete(r.schedules, name)
+	return ok
+}
+
+// finished records that a run is done, waking runSchedules to start the next queued run.
+func (r *recurring) finished(id string) {
+	r.mu.Lock()
+	run, ok := r.runs[id]
+	if ok {
+		delete(r.runs, id)
+		delete(run.schedule.running, id)
+	}
+	r.mu.Unlock()
+	if ok {
+		r.notify()
+	}
+}
+
+// unstart undoes the start of runs which could not be enqueued, latest first, so that
+// the schedules report the runs which were actually enqueued.
+func (r *recurring) unstart(works []Work) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(works) - 1; i >= 0; i-- {
+		run, ok := r.runs[works[i].ID]
+		if !ok {
+			continue
+		}
+		rs := run.schedule
+		delete(r.runs, works[i].ID)
+		delete(rs.running, works[i].ID)
+		rs.runs--
+		rs.last = run.last
+		if run.queued {
+			rs.queued = append([]time.Time{run.at}, rs.queued...)
+		}
+	}
+}
+
+// due returns the Work of the runs which are due, the names of the schedules whose run
+// was skipped, and when the next run is due.
+func (r *recurring) due() ([]Work, []string, <-chan time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.clock.Now()
+	var works []Work
+	var skipped []string
+	var earliest time.Time
+	start := func(rs *recurringSchedule, at time.Time, queued bool) {
+		run := scheduleRun{schedule: rs, at: at, last: rs.last, queued: queued}
+		work := rs.start(at)
+		r.runs[work.ID] = run
+		works = append(works, work)
+	}
+	for _, rs := range r.schedules {
+		if len(rs.running) == 0 && len(rs.queued) > 0 {
+			start(rs, rs.queued[0], true)
+			rs.queued = rs.queued[1:]
+		}
+		if !rs.next.After(now) {
+			run := rs.next
+			// runs missed while the herd was busy are not caught up
+			if rs.next = rs.spec.next(run); !rs.next.After(now) {
+				rs.next = rs.spec.next(now)
+			}
+			switch {
+			case len(rs.running) == 0 && len(rs.queued) == 0, rs.Overlap == OverlapAllow:
+				start(rs, run, false)
+			case rs.Overlap == OverlapQueue:
+				rs.queued = append(rs.queued, run)
+			default:
+				rs.skipped++
+				skipped = append(skipped, rs.Name)
+			}
+		}
+		if !rs.next.IsZero() && (earliest.IsZero() || rs.next.Before(earliest)) {
+			earliest = rs.next
+		}
+	}
+	var wait <-chan time.Time
+	if !earliest.IsZero() {
+		wait = r.clock.After(earliest.Sub(now))
+	}
+	return works, skipped, wait
+}
+
+func (r *recurring) infos() []ScheduleInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	infos := make([]ScheduleInfo, 0, len(r.schedules))
+	for _, rs := range r.schedules {
+		infos = append(infos, rs.info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// runSchedules enqueues the runs of the schedules when they are due, until the herd
+// is done or the input chan is closed.
+func (gf *Gofherd) runSchedules() {
+	for {
+		works, skipped, wait := gf.recurring.due()
+		for _, name := range skipped {
+			gf.log.warn("skipped overlapping schedule run", Field{"schedule", name})
+		}
+		for i, work := range works {
+			if !gf.scheduleWork(work, time.Time{}) {
+				gf.recurring.unstart(works[i:])
+				gf.log.info("stopped schedules, input chan is closed")
+				return
+			}
+			gf.log.debug("enqueued schedule run", Field{"work_id", work.ID})
+		}
+		select {
+		case <-wait:
+		case <-gf.recurring.wake:
+		case <-gf.done:
+			return
+		}
+	}
+}
+
+// AddSchedule registers recurring Work, enqueued into the herd once it is started,
+// whenever the Schedule is due. Runs missed while the herd could not keep up are
+// not caught up. Schedules stop when the input chan is closed, so a herd with
+// schedules is usually kept running without calling CloseInputChan.
+// It returns ErrScheduleExists if a schedule with the same name is registered.
+func (gf *Gofherd) AddSchedule(s Schedule) error {
+	if err := gf.recurring.add(s); err != nil {
+		return err
+	}
+	gf.log.info("added schedule", Field{"schedule", s.Name})
+	return nil
+}
+
+// RemoveSchedule unregisters the schedule with the name, runs already enqueued are
+// still processed. It returns false if there is no such schedule.
+func (gf *Gofherd) RemoveSchedule(name string) bool {
+	removed := gf.recurring.remove(name)
+	if removed {
+		gf.log.info("removed schedule", Field{"schedule", name})
+	}
+	return removed
+}
+
+// Schedules returns the registered schedules, sorted by name.
+func (gf *Gofherd) Schedules() []ScheduleInfo {
+	return gf.recurring.infos()
+}
+
+func (gf *Gofherd) schedulesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, gf.Schedules())
+}
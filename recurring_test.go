@@ -0,0 +1,186 @@
+package gofherd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func getScheduledGopherd(processingLogic func(*Work) Status) *Gofherd {
+	gf := New(processingLogic)
+	gf.SetHerdSize(2)
+	gf.SetAddr("127.0.0.1:0")
+	return gf
+}
+
+func waitForRuns(gf *Gofherd, name string, runs uint64, t *testing.T) ScheduleInfo {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, info := range gf.Schedules() {
+			if info.Name == name && info.Runs >= runs {
+				return info
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected schedule %s to run %d times", name, runs)
+	return ScheduleInfo{}
+}
+
+func TestScheduleEveryEnqueuesRuns(t *testing.T) {
+	var mu sync.Mutex
+	ids := map[string]bool{}
+	gf := getScheduledGopherd(func(w *Work) Status {
+		mu.Lock()
+		defer mu.Unlock()
+		if w.Body != "probe" || !strings.HasPrefix(w.ID, "probe@") {
+			t.Errorf("unexpected work %s with body %v", w.ID, w.Body)
+		}
+		ids[w.ID] = true
+		return Success
+	})
+	if err := gf.AddSchedule(Schedule{Name: "probe", Every: 20 * time.Millisecond, Body: "probe"}); err != nil {
+		t.Fatalf("could not add schedule: %s", err)
+	}
+	gf.Start()
+	waitForRuns(gf, "probe", 3, t)
+	gf.CloseInputChan()
+	for work := range gf.OutputChan() {
+		if work.Status() != Success {
+			t.Fatalf("expected success, got: %s", work.Status())
+		}
+	}
+	if len(ids) < 3 {
+		t.Fatalf("expected at least 3 runs with unique IDs, got: %v", ids)
+	}
+}
+
+func TestScheduleOverlapSkip(t *testing.T) {
+	release := make(chan struct{})
+	gf := getScheduledGopherd(func(w *Work) Status {
+		<-release
+		return Success
+	})
+	gf.AddSchedule(Schedule{Name: "slow", Every: 10 * time.Millisecond})
+	gf.Start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	info := ScheduleInfo{}
+	for info.Skipped < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		info = gf.Schedules()[0]
+	}
+	if info.Runs != 1 || info.Running != 1 || info.Skipped < 3 {
+		t.Fatalf("expected one run and skipped overlapping runs, got: %+v", info)
+	}
+	gf.RemoveSchedule("slow")
+	close(release)
+	gf.CloseInputChan()
+	for range gf.OutputChan() {
+	}
+}
+
+func TestScheduleOverlapQueue(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	gf := getScheduledGopherd(func(w *Work) Status {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(30 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return Success
+	})
+	gf.AddSchedule(Schedule{Name: "serial", Every: 10 * time.Millisecond, Overlap: OverlapQueue})
+	gf.Start()
+
+	info := waitForRuns(gf, "serial", 3, t)
+	if info.Skipped != 0 {
+		t.Fatalf("expected no skipped runs, got: %+v", info)
+	}
+	gf.RemoveSchedule("serial")
+	gf.CloseInputChan()
+	for range gf.OutputChan() {
+	}
+	if maxRunning != 1 {
+		t.Fatalf("expected queued runs not to overlap, got %d at once", maxRunning)
+	}
+}
+
+func TestSchedulesStoppedByCloseInputChan(t *testing.T) {
+	gf := getScheduledGopherd(func(w *Work) Status { return Success })
+	gf.AddSchedule(Schedule{Name: "a", Every: time.Millisecond})
+	gf.AddSchedule(Schedule{Name: "b", Every: time.Millisecond})
+	time.Sleep(5 * time.Millisecond)
+	gf.CloseInputChan()
+	// both runs are due, and neither can be enqueued
+	gf.runSchedules()
+
+	for _, info := range gf.Schedules() {
+		if info.Runs != 0 || info.Running != 0 || info.LastRun != nil {
+			t.Fatalf("expected runs which were not enqueued to be undone, got: %+v", info)
+		}
+	}
+	if len(gf.recurring.runs) != 0 {
+		t.Fatalf("expected no runs left, got: %v", gf.recurring.runs)
+	}
+}
+
+func TestAddScheduleErrors(t *testing.T) {
+	gf := getScheduledGopherd(func(w *Work) Status { return Success })
+	if err := gf.AddSchedule(Schedule{Name: "a", Cron: "@daily"}); err != nil {
+		t.Fatalf("could not add schedule: %s", err)
+	}
+	if err := gf.AddSchedule(Schedule{Name: "a", Cron: "@hourly"}); !errors.Is(err, ErrScheduleExists) {
+		t.Fatalf("expected ErrScheduleExists, got: %v", err)
+	}
+	for _, s := range []Schedule{
+		{Cron: "@daily"},
+		{Name: "b"},
+		{Name: "b", Cron: "@daily", Every: time.Second},
+		{Name: "b", Cron: "* * *"},
+		{Name: "b", Cron: "0 0 31 2 *"},
+		{Name: "b", Every: time.Second, Overlap: OverlapPolicy(10)},
+	} {
+		if err := gf.AddSchedule(s); err == nil {
+			t.Fatalf("expected an error adding %+v", s)
+		}
+	}
+	if gf.RemoveSchedule("b") {
+		t.Fatalf("expected no schedule b to remove")
+	}
+}
+
+func TestSchedulesHandler(t *testing.T) {
+	gf := getScheduledGopherd(func(w *Work) Status { return Success })
+	gf.AddSchedule(Schedule{Name: "nightly", Cron: "0 2 * * *", Location: time.UTC, Overlap: OverlapQueue})
+	gf.AddSchedule(Schedule{Name: "every", Every: time.Hour})
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/schedules", nil)
+	gf.Handler().ServeHTTP(resp, req)
+	var infos []ScheduleInfo
+	if err := json.Unmarshal(resp.Body.Bytes(), &infos); err != nil {
+		t.Fatalf("could not decode response: %s, %s", err, resp.Body.String())
+	}
+	if len(infos) != 2 || infos[0].Name != "every" || infos[1].Name != "nightly" {
+		t.Fatalf("expected both schedules sorted by name, got: %+v", infos)
+	}
+	if infos[0].Spec != "@every 1h0m0s" || time.Until(infos[0].Next) < 59*time.Minute {
+		t.Fatalf("expected the interval schedule to run in an hour, got: %+v", infos[0])
+	}
+	nightly := infos[1]
+	if nightly.Spec != "0 2 * * *" || nightly.Overlap != "queue" || nightly.Next.UTC().Hour() != 2 || nightly.LastRun != nil {
+		t.Fatalf("expected the cron schedule to run at 2:00, got: %+v", nightly)
+	}
+}
@@ -0,0 +1,259 @@
+package gofherd
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrWorkNotFound is returned by Cancel for an unknown Work ID.
+	ErrWorkNotFound = errors.New("work not found")
+	// ErrWorkDone is returned by Cancel for Work which has already reached a final status.
+	ErrWorkDone = errors.New("work already done")
+)
+
+const (
+	// workHistoryLimit is the number of completed Work units whose history is kept for inspection.
+	workHistoryLimit = 10000
+	// attemptHistoryLimit is the number of most recent attempts kept per Work unit.
+	attemptHistoryLimit = 100
+)
+
+// WorkState is where a Work unit currently is in the herd.
+type WorkState string
+
+const (
+	// StateQueued is Work sent with SendWork, not yet picked up by a gopher.
+	StateQueued WorkState = "queued"
+	// StateScheduled is Work sent with SendWorkAt or SendWorkAfter, which is not yet due.
+	StateScheduled WorkState = "scheduled"
+	// StateWaiting is Work sent with DependsOn, waiting for its dependencies to succeed.
+	StateWaiting WorkState = "waiting"
+	// StateInFlight is Work being processed by the processing logic.
+	StateInFlight WorkState = "in_flight"
+	// StateRetrying is Work waiting in the retry path for a gopher.
+	StateRetrying WorkState = "retrying"
+	// StateDone is Work which has reached a final status.
+	StateDone WorkState = "done"
+)
+
+// Attempt is a single run of the processing logic on a Work unit.
+type Attempt struct {
+	Attempt  int64      `json:"attempt"`
+	GopherID int64      `json:"gopher_id"`
+	Started  time.Time  `json:"started"`
+	Ended    *time.Time `json:"ended,omitempty"`
+	Status   string     `json:"status,omitempty"`
+}
+
+// WorkInfo is the state and attempt history of a Work unit.
+type WorkInfo struct {
+	ID       string    `json:"id"`
+	State    WorkState `json:"state"`
+	Status   string    `json:"status,omitempty"`
+	Attempts []Attempt `json:"attempts"`
+}
+
+// InFlightWork is a Work unit currently inside the processing logic.
+type InFlightWork struct {
+	ID       string    `json:"id"`
+	GopherID int64     `json:"gopher_id"`
+	Attempt  int64     `json:"attempt"`
+	Started  time.Time `json:"started"`
+}
+
+type workRegistry struct {
+	mu        sync.Mutex
+	records   map[string]*WorkInfo
+	completed []string
+	cancels   map[string]context.CancelFunc
+	cancelled map[string]bool
+	now       func() time.Time
+}
+
+func newWorkRegistry() *workRegistry {
+	return &workRegistry{
+		records:   make(map[string]*WorkInfo),
+		cancels:   make(map[string]context.CancelFunc),
+		cancelled: make(map[string]bool),
+		now:       time.Now,
+	}
+}
+
+func (wr *workRegistry) queued(id string) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	wr.records[id] = &WorkInfo{ID: id, State: StateQueued, Attempts: []Attempt{}}
+	delete(wr.cancelled, id)
+}
+
+func (wr *workRegistry) scheduled(id string) {
+	wr.add(id, StateScheduled)
+}
+
+func (wr *workRegistry) waiting(id string) {
+	wr.add(id, StateWaiting)
+}
+
+func (wr *workRegistry) add(id string, state WorkState) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	wr.records[id] = &WorkInfo{ID: id, State: state, Attempts: []Attempt{}}
+	delete(wr.cancelled, id)
+}
+
+// held moves scheduled Work which is due to the waiting state, while it waits for
+// its dependencies.
+func (wr *workRegistry) held(id string) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	wr.record(id).State = StateWaiting
+}
+
+// due moves scheduled or waiting Work to the queued state.
+func (wr *workRegistry) due(id string) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	wr.record(id).State = StateQueued
+}
+
+// started records the start of an attempt, unless the Work has been cancelled,
+// in which case it returns true. The cancel func is called if the Work is cancelled while in flight.
+func (wr *workRegistry) started(id string, attempt, gopher int64, cancel context.CancelFunc) bool {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	if wr.cancelled[id] {
+		return true
+	}
+	record := wr.record(id)
+	record.State = StateInFlight
+	record.Attempts = append(record.Attempts, Attempt{Attempt: attempt, GopherID: gopher, Started: wr.now()})
+	if len(record.Attempts) > attemptHistoryLimit {
+		record.Attempts = record.Attempts[1:]
+	}
+	wr.cancels[id] = cancel
+	return false
+}
+
+// finished records the end of an attempt and returns the status of the Work, which is
+// Cancelled if it was cancelled meanwhile. Unless the Work is retried, it is done from
+// then on, so that Cancel cannot succeed for Work about to be emitted with another status.
+func (wr *workRegistry) finished(id string, status Status, exhausted bool) Status {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	delete(wr.cancels, id)
+	if wr.cancelled[id] {
+		status = Cancelled
+	}
+	record := wr.record(id)
+	if n := len(record.Attempts); n > 0 {
+		ended := wr.now()
+		record.Attempts[n-1].Ended = &ended
+		record.Attempts[n-1].Status = status.String()
+	}
+	if status != Retry || exhausted {
+		record.State = StateDone
+	}
+	return status
+}
+
+// retrying records that the Work waits to be retried. The wake func is called if the
+// Work is cancelled meanwhile, to stop waiting.
+func (wr *workRegistry) retrying(id string, wake context.CancelFunc) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	wr.record(id).State = StateRetrying
+	wr.cancels[id] = wake
+}
+
+func (wr *workRegistry) done(id string, status Status) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	record := wr.record(id)
+	record.State = StateDone
+	record.Status = status.String()
+	delete(wr.cancelled, id)
+	wr.completed = append(wr.completed, id)
+	if len(wr.completed) > workHistoryLimit {
+		evicted := wr.completed[0]
+		wr.completed = wr.completed[1:]
+		if r, ok := wr.records[evicted]; ok && r.State == StateDone {
+			delete(wr.records, evicted)
+		}
+	}
+}
+
+func (wr *workRegistry) cancel(id string) error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	record, ok := wr.records[id]
+	if !ok {
+		return ErrWorkNotFound
+	}
+	if record.State == StateDone {
+		return ErrWorkDone
+	}
+	wr.cancelled[id] = true
+	if cancel, ok := wr.cancels[id]; ok {
+		cancel()
+	}
+	return nil
+}
+
+func (wr *workRegistry) isCancelled(id string) bool {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	return wr.cancelled[id]
+}
+
+// record returns the record for the ID, creating it if it is missing.
+// It must be called with the lock held.
+func (wr *workRegistry) record(id string) *WorkInfo {
+	record, ok := wr.records[id]
+	if !ok {
+		record = &WorkInfo{ID: id, State: StateQueued, Attempts: []Attempt{}}
+		wr.records[id] = record
+	}
+	return record
+}
+
+func (wr *workRegistry) get(id string) (WorkInfo, bool) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	record, ok := wr.records[id]
+	if !ok {
+		return WorkInfo{}, false
+	}
+	return copyWorkInfo(record), true
+}
+
+func (wr *workRegistry) inState(state WorkState) []WorkInfo {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	infos := []WorkInfo{}
+	for _, record := range wr.records {
+		if record.State == state {
+			infos = append(infos, copyWorkInfo(record))
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+func copyWorkInfo(record *WorkInfo) WorkInfo {
+	info := *record
+	info.Attempts = append([]Attempt{}, record.Attempts...)
+	return info
+}
+
+func (wr *workRegistry) inFlight() []InFlightWork {
+	inFlight := []InFlightWork{}
+	for _, info := range wr.inState(StateInFlight) {
+		last := info.Attempts[len(info.Attempts)-1]
+		inFlight = append(inFlight, InFlightWork{ID: info.ID, GopherID: last.GopherID, Attempt: last.Attempt, Started: last.Started})
+	}
+	return inFlight
+}
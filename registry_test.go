@@ -0,0 +1,100 @@
+package gofherd
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWorkRegistryLifecycle(t *testing.T) {
+	wr := newWorkRegistry()
+	wr.queued("abc")
+	wr.started("abc", 1, 7, func() {})
+
+	inFlight := wr.inFlight()
+	if len(inFlight) != 1 || inFlight[0].ID != "abc" || inFlight[0].GopherID != 7 || inFlight[0].Attempt != 1 {
+		t.Fatalf("did not get expected in flight work, got: %+v", inFlight)
+	}
+
+	wr.finished("abc", Retry, false)
+	wr.retrying("abc", func() {})
+	if retrying := wr.inState(StateRetrying); len(retrying) != 1 || len(wr.inFlight()) != 0 {
+		t.Fatalf("expected work to be retrying, got: %+v", retrying)
+	}
+
+	wr.started("abc", 2, 3, func() {})
+	wr.finished("abc", Success, false)
+	wr.done("abc", Success)
+
+	info, ok := wr.get("abc")
+	if !ok || info.State != StateDone || info.Status != "success" || len(info.Attempts) != 2 {
+		t.Fatalf("did not get expected work info, got: %+v", info)
+	}
+	if info.Attempts[0].Status != "retry" || info.Attempts[1].GopherID != 3 || info.Attempts[1].Ended == nil {
+		t.Fatalf("did not get expected attempt history, got: %+v", info.Attempts)
+	}
+}
+
+func TestWorkRegistryEvictsOldCompletedWork(t *testing.T) {
+	wr := newWorkRegistry()
+	for i := 0; i < workHistoryLimit+1; i++ {
+		id := fmt.Sprintf("%d", i)
+		wr.queued(id)
+		wr.done(id, Success)
+	}
+	if _, ok := wr.get("0"); ok {
+		t.Fatalf("expected oldest completed work to be evicted")
+	}
+	if _, ok := wr.get(fmt.Sprintf("%d", workHistoryLimit)); !ok {
+		t.Fatalf("expected latest completed work to be kept")
+	}
+}
+
+func TestWorkRegistryCancel(t *testing.T) {
+	wr := newWorkRegistry()
+	if err := wr.cancel("abc"); err != ErrWorkNotFound {
+		t.Fatalf("expected ErrWorkNotFound, got: %v", err)
+	}
+
+	wr.queued("abc")
+	cancelCalled := false
+	wr.started("abc", 1, 1, func() { cancelCalled = true })
+	if err := wr.cancel("abc"); err != nil || !cancelCalled || !wr.isCancelled("abc") {
+		t.Fatalf("expected in flight work to be cancelled, err: %v, cancel called: %t", err, cancelCalled)
+	}
+	wr.finished("abc", Cancelled, false)
+	if cancelled := wr.started("abc", 2, 1, func() {}); !cancelled {
+		t.Fatalf("expected started to report cancelled work")
+	}
+
+	wr.done("abc", Cancelled)
+	if err := wr.cancel("abc"); err != ErrWorkDone {
+		t.Fatalf("expected ErrWorkDone, got: %v", err)
+	}
+}
+
+func TestWorkRegistryFinishedDecidesWithCancel(t *testing.T) {
+	wr := newWorkRegistry()
+	wr.queued("abc")
+	wr.started("abc", 1, 1, func() {})
+	wr.cancel("abc")
+	if status := wr.finished("abc", Success, false); status != Cancelled {
+		t.Fatalf("expected work cancelled before it finished to be cancelled, got: %s", status)
+	}
+
+	wr.queued("def")
+	wr.started("def", 1, 1, func() {})
+	if status := wr.finished("def", Success, false); status != Success {
+		t.Fatalf("expected success, got: %s", status)
+	}
+	// the Work is emitted as Success, so cancelling it now must fail
+	if err := wr.cancel("def"); err != ErrWorkDone {
+		t.Fatalf("expected ErrWorkDone between finished and done, got: %v", err)
+	}
+
+	wr.queued("ghi")
+	wr.started("ghi", 1, 1, func() {})
+	wr.finished("ghi", Retry, true)
+	if err := wr.cancel("ghi"); err != ErrWorkDone {
+		t.Fatalf("expected ErrWorkDone for work which exhausted its retries, got: %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package gofherd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// httpBodyLimit is the default number of bytes of the response body kept in HTTPResult.
+	httpBodyLimit = 1 << 20
+	// maxRetryAfter is the default longest Retry-After honored by HTTPProcessor.
+	maxRetryAfter = 5 * time.Minute
+)
+
+// HTTPRequest is the Body of Work processed by an HTTPProcessor.
+// A string Body is also accepted, as the URL of a GET request.
+type HTTPRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// HTTPRule maps the response status codes from Min to Max, inclusive, to a Status.
+type HTTPRule struct {
+	Min    int
+	Max    int
+	Status Status
+}
+
+// DefaultHTTPRules treat 2xx as Success, 429 and 5xx as Retry, and other 4xx as Failure.
+var DefaultHTTPRules = []HTTPRule{
+	{Min: 200, Max: 299, Status: Success},
+	{Min: 429, Max: 429, Status: Retry},
+	{Min: 500, Max: 599, Status: Retry},
+	{Min: 400, Max: 499, Status: Failure},
+}
+
+// HTTPOptions configures the processing logic of NewHTTPProcessor.
+type HTTPOptions struct {
+	// Client sends the requests, it defaults to http.DefaultClient.
+	Client *http.Client
+	// Rules classify responses, the first matching rule wins. Responses matching no rule
+	// are a Failure. It defaults to DefaultHTTPRules.
+	Rules []HTTPRule
+	// MaxBodySize is the number of bytes of the response body kept, it defaults to 1MB.
+	MaxBodySize int64
+	// MaxRetryAfter caps the delay honored from a `Retry-After` header of a response
+	// classified as Retry, it defaults to 5 minutes.
+	MaxRetryAfter time.Duration
+}
+
+// HTTPResult is set as the Result of Work processed by an HTTPProcessor.
+type HTTPResult struct {
+	StatusCode int           `json:"status_code"`
+	Header     http.Header   `json:"header,omitempty"`
+	Body       []byte        `json:"body,omitempty"`
+	Truncated  bool          `json:"truncated,omitempty"`
+	Latency    time.Duration `json:"latency"`
+	// Error is set when the request could not be made or the response could not be read.
+	Error string `json:"error,omitempty"`
+}
+
+// HTTPProcessor is processing logic sending an HTTP request per Work unit. Pass its
+// Process method to NewWithContext, the request is cancelled with the attempt.
+type HTTPProcessor struct {
+	opts HTTPOptions
+}
+
+// NewHTTPProcessor returns an HTTPProcessor.
+func NewHTTPProcessor(opts HTTPOptions) *HTTPProcessor {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Rules == nil {
+		opts.Rules = DefaultHTTPRules
+	}
+	// a copy, so that changing the rules later does not affect the processor
+	opts.Rules = append([]HTTPRule(nil), opts.Rules...)
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = httpBodyLimit
+	}
+	if opts.MaxRetryAfter <= 0 {
+		opts.MaxRetryAfter = maxRetryAfter
+	}
+	return &HTTPProcessor{opts: opts}
+}
+
+func (hp *HTTPProcessor) newRequest(ctx context.Context, body interface{}) (*http.Request, error) {
+	var r HTTPRequest
+	switch b := body.(type) {
+	case HTTPRequest:
+		r = b
+	case *HTTPRequest:
+		if b == nil {
+			return nil, errors.New("nil HTTPRequest")
+		}
+		r = *b
+	case string:
+		r = HTTPRequest{URL: b}
+	default:
+		return nil, fmt.Errorf("unsupported body %T, expected HTTPRequest or string", body)
+	}
+	if r.Method == "" {
+		r.Method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.URL, bytes.NewReader(r.Body))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range r.Header {
+		req.Header[http.CanonicalHeaderKey(key)] = append([]string{}, values...)
+	}
+	return req, nil
+}
+
+func (hp *HTTPProcessor) status(code int) Status {
+	for _, rule := range hp.opts.Rules {
+		if code >= rule.Min && code <= rule.Max {
+			return rule.Status
+		}
+	}
+	return Failure
+}
+
+// Process sends the request described by the Work Body and sets an HTTPResult as its Result.
+// Work with an invalid Body is a Failure, and a request which fails without a response is retried.
+func (hp *HTTPProcessor) Process(ctx context.Context, w *Work) Status {
+	result := HTTPResult{}
+	defer func() { w.SetResult(result) }()
+	req, err := hp.newRequest(ctx, w.Body)
+	if err != nil {
+		result.Error = err.Error()
+		return Failure
+	}
+
+	start := time.Now()
+	resp, err := hp.opts.Client.Do(req)
+	if err != nil {
+		result.Latency = time.Since(start)
+		result.Error = err.Error()
+		return Retry
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, hp.opts.MaxBodySize+1))
+	result.Latency = time.Since(start)
+	result.StatusCode = resp.StatusCode
+	result.Header = resp.Header
+	if int64(len(body)) > hp.opts.MaxBodySize {
+		body = body[:hp.opts.MaxBodySize]
+		result.Truncated = true
+	}
+	result.Body = body
+	if err != nil {
+		result.Error = err.Error()
+		return Retry
+	}
+
+	status := hp.status(resp.StatusCode)
+	if status == Retry {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			if delay > hp.opts.MaxRetryAfter {
+				delay = hp.opts.MaxRetryAfter
+			}
+			w.SetRetryAfter(delay)
+		}
+	}
+	return status
+}
+
+// parseRetryAfter parses a `Retry-After` header, in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		// a delay which does not fit in a time.Duration is the longest one
+		if seconds > int(math.MaxInt64/int64(time.Second)) {
+			return time.Duration(math.MaxInt64), true
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if delay := date.Sub(now); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}
@@ -0,0 +1,141 @@
+package gofherd
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPProcessorClassifiesResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := map[string]int{"/ok": 200, "/missing": 404, "/busy": 429, "/down": 503, "/moved": 304}[r.URL.Path]
+		w.WriteHeader(code)
+	}))
+	defer server.Close()
+
+	hp := NewHTTPProcessor(HTTPOptions{})
+	for path, expected := range map[string]Status{"/ok": Success, "/missing": Failure, "/busy": Retry, "/down": Retry, "/moved": Failure} {
+		work := Work{ID: path, Body: server.URL + path}
+		if status := hp.Process(context.Background(), &work); status != expected {
+			t.Fatalf("expected %s for %s, got: %s", expected, path, status)
+		}
+	}
+
+	rules := []HTTPRule{{Min: 404, Max: 404, Status: Success}}
+	hp = NewHTTPProcessor(HTTPOptions{Rules: rules})
+	work := Work{ID: "a", Body: server.URL + "/missing"}
+	if status := hp.Process(context.Background(), &work); status != Success {
+		t.Fatalf("expected custom rules to be used, got: %s", status)
+	}
+
+	// the processors do not share the rules they were created with
+	rules[0].Status = Failure
+	if status := hp.Process(context.Background(), &work); status != Success {
+		t.Fatalf("expected the rules to be copied, got: %s", status)
+	}
+	defaults := NewHTTPProcessor(HTTPOptions{})
+	defaults.opts.Rules[0].Status = Failure
+	if DefaultHTTPRules[0].Status != Success {
+		t.Fatalf("expected changing the rules of a processor to leave DefaultHTTPRules alone")
+	}
+}
+
+func TestHTTPProcessorRequestAndResult(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Token", r.Header.Get("X-Token"))
+		w.Write(append([]byte("echo:"), body...))
+	}))
+	defer server.Close()
+
+	hp := NewHTTPProcessor(HTTPOptions{MaxBodySize: 8})
+	work := Work{ID: "a", Body: HTTPRequest{Method: http.MethodPost, URL: server.URL, Header: http.Header{"x-token": {"secret"}}, Body: []byte("hello")}}
+	if status := hp.Process(context.Background(), &work); status != Success {
+		t.Fatalf("expected success, got: %s, %+v", status, work.Result())
+	}
+	result := work.Result().(HTTPResult)
+	if result.StatusCode != 200 || result.Header.Get("X-Method") != "POST" || result.Header.Get("X-Token") != "secret" {
+		t.Fatalf("did not get expected response, got: %+v", result)
+	}
+	if string(result.Body) != "echo:hel" || !result.Truncated || result.Latency <= 0 {
+		t.Fatalf("expected the body to be capped at 8 bytes, got: %q, truncated: %v", result.Body, result.Truncated)
+	}
+}
+
+func TestHTTPProcessorErrors(t *testing.T) {
+	hp := NewHTTPProcessor(HTTPOptions{})
+	work := Work{ID: "a", Body: 42}
+	if status := hp.Process(context.Background(), &work); status != Failure || work.Result().(HTTPResult).Error == "" {
+		t.Fatalf("expected failure for an unsupported body, got: %s", status)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+	work = Work{ID: "b", Body: &HTTPRequest{URL: url}}
+	if status := hp.Process(context.Background(), &work); status != Retry || work.Result().(HTTPResult).Error == "" {
+		t.Fatalf("expected retry when the server is down, got: %s", status)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		value string
+		delay time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"-1", 0, false},
+		{"Wed, 01 Jan 2020 00:00:30 GMT", 30 * time.Second, true},
+		{"Tue, 31 Dec 2019 00:00:00 GMT", 0, true},
+		{"soon", 0, false},
+		{"9223372036854775807", time.Duration(math.MaxInt64), true},
+		{"10000000000000", time.Duration(math.MaxInt64), true},
+	}
+	for _, tc := range cases {
+		if delay, ok := parseRetryAfter(tc.value, now); delay != tc.delay || ok != tc.ok {
+			t.Fatalf("expected %s, %v for %q, got: %s, %v", tc.delay, tc.ok, tc.value, delay, ok)
+		}
+	}
+}
+
+func TestHTTPProcessorHonorsRetryAfter(t *testing.T) {
+	calls := int64(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	gf := NewWithContext(NewHTTPProcessor(HTTPOptions{MaxRetryAfter: 50 * time.Millisecond}).Process)
+	gf.SetHerdSize(1)
+	gf.SetMaxRetries(1)
+	gf.SetRetryBackoff(time.Hour)
+	gf.SetAddr("127.0.0.1:0")
+	go func() {
+		gf.SendWork(Work{ID: "a", Body: server.URL})
+		gf.CloseInputChan()
+	}()
+	gf.Start()
+
+	select {
+	case work := <-gf.OutputChan():
+		if work.Status() != Success || work.Retries() != 1 || string(work.Result().(HTTPResult).Body) != "ok" {
+			t.Fatalf("expected success after one retry, got: %s after %d retries", work.Status(), work.Retries())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the capped Retry-After to replace the retry backoff")
+	}
+}
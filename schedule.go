@@ -0,0 +1,175 @@
+package gofherd
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// scheduledWork is Work waiting in the scheduler until at.
+type scheduledWork struct {
+	at   time.Time
+	seq  uint64
+	work Work
+}
+
+// workHeap orders scheduled Work by time, and by the order it was sent for the same time.
+type workHeap []scheduledWork
+
+func (h workHeap) Len() int { return len(h) }
+
+func (h workHeap) Less(i, j int) bool {
+	if h[i].at.Equal(h[j].at) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].at.Before(h[j].at)
+}
+
+func (h workHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *workHeap) Push(x interface{}) { *h = append(*h, x.(scheduledWork)) }
+
+func (h *workHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// scheduler holds Work sent with SendWorkAt until it is due, and then hands it
+// to the gophers on the due chan.
+type scheduler struct {
+	mu      sync.Mutex
+	heap    workHeap
+	seq     uint64
+	clock   Clock
+	wake    chan struct{}
+	due     chan Work
+	metrics *metrics
+}
+
+func newScheduler(clock Clock, m *metrics) *scheduler {
+	return &scheduler{clock: clock, wake: make(chan struct{}, 1), due: make(chan Work), metrics: m}
+}
+
+func (s *scheduler) add(work Work, at time.Time) {
+	s.mu.Lock()
+	heap.Push(&s.heap, scheduledWork{at: at, seq: s.seq, work: work})
+	s.seq++
+	s.mu.Unlock()
+	s.metrics.addScheduled(1)
+	s.notify()
+}
+
+// expedite makes the scheduled Work with the ID due right away.
+func (s *scheduler) expedite(id string) {
+	s.mu.Lock()
+	found := false
+	for i := range s.heap {
+		if s.heap[i].work.ID == id {
+			s.heap[i].at = time.Time{}
+			heap.Fix(&s.heap, i)
+			found = true
+		}
+	}
+	s.mu.Unlock()
+	if found {
+		s.notify()
+	}
+}
+
+func (s *scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.heap)
+}
+
+// next pops the first Work if it is due, or returns how long to wait for it.
+// It waits for a notification if nothing is scheduled.
+func (s *scheduler) next() (Work, bool, <-chan time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.heap) == 0 {
+		return Work{}, false, nil
+	}
+	wait := s.heap[0].at.Sub(s.clock.Now())
+	if wait > 0 {
+		return Work{}, false, s.clock.After(wait)
+	}
+	item := heap.Pop(&s.heap).(scheduledWork)
+	return item.work, true, nil
+}
+
+// run hands due Work to the gophers until done is closed, unless released returns
+// false because the Work is held elsewhere.
+func (s *scheduler) run(done <-chan struct{}, released func(*Work) bool) {
+	for {
+		work, ok, wait := s.next()
+		if ok {
+			s.metrics.addScheduled(-1)
+			if !released(&work) {
+				continue
+			}
+			select {
+			case s.due <- work:
+			case <-done:
+				return
+			}
+			continue
+		}
+		select {
+		case <-wait:
+		case <-s.wake:
+		case <-done:
+			return
+		}
+	}
+}
+
+// SendWorkAt enqueues Work which is not handed to a gopher before t. It returns right
+// away, the Work waits in a timer heap and is sent in order of t once due. Scheduled
+// Work counts toward completion, so the herd does not finish after CloseInputChan
+// until it has been processed. SendWorkAt must not be called after CloseInputChan.
+// Cancel makes scheduled Work due right away, to be emitted as Cancelled.
+func (gf *Gofherd) SendWorkAt(work Work, t time.Time) {
+	if !gf.scheduleWork(work, t) {
+		panic("gofherd: SendWorkAt called after CloseInputChan")
+	}
+}
+
+// scheduleWork adds the Work to the scheduler, unless the input chan is closed.
+func (gf *Gofherd) scheduleWork(work Work, t time.Time) bool {
+	// count the Work under the input lock, so that CloseInputChan cannot complete the herd
+	// before it is processed, and only then record it
+	gf.input.lock()
+	if gf.input.closed() {
+		gf.input.unlock()
+		return false
+	}
+	gf.input.increment()
+	gf.input.unlock()
+	gf.prepareWork(context.Background(), &work, StateScheduled)
+	gf.scheduler.add(work, t)
+	gf.log.debug("scheduled work", Field{"work_id", work.ID}, Field{"at", t})
+	return true
+}
+
+// SendWorkAfter enqueues Work which is not handed to a gopher before d has elapsed,
+// like SendWorkAt.
+func (gf *Gofherd) SendWorkAfter(work Work, d time.Duration) {
+	gf.SendWorkAt(work, gf.clock.Now().Add(d))
+}
+
+// Scheduled returns the number of Work units sent with SendWorkAt or SendWorkAfter
+// which are not yet due.
+func (gf *Gofherd) Scheduled() int {
+	return gf.scheduler.count()
+}
@@ -0,0 +1,166 @@
+package gofherd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSendWorkAfterDelaysWork(t *testing.T) {
+	var mu sync.Mutex
+	processed := map[string]time.Time{}
+	order := []string{}
+	gf := New(func(w *Work) Status {
+		mu.Lock()
+		defer mu.Unlock()
+		processed[w.ID] = time.Now()
+		order = append(order, w.ID)
+		return Success
+	})
+	gf.SetHerdSize(2)
+	gf.SetAddr("127.0.0.1:0")
+	gf.SetMetricsRegistry(prometheus.NewRegistry())
+	gf.Start()
+
+	start := time.Now()
+	gf.SendWorkAfter(Work{ID: "late"}, 150*time.Millisecond)
+	gf.SendWorkAt(Work{ID: "soon"}, start.Add(50*time.Millisecond))
+	gf.SendWork(Work{ID: "now"})
+	if scheduled := gf.Scheduled(); scheduled != 2 {
+		t.Fatalf("expected 2 scheduled work units, got: %d", scheduled)
+	}
+	if gauge := testutil.ToFloat64(gf.metrics.scheduled); gauge != 2 {
+		t.Fatalf("expected the scheduled gauge to be 2, got: %f", gauge)
+	}
+	if info, _ := gf.WorkInfo("late"); info.State != StateScheduled {
+		t.Fatalf("expected late to be scheduled, got: %s", info.State)
+	}
+	// closing the input chan must not complete the herd before scheduled Work is processed
+	gf.CloseInputChan()
+
+	completed := 0
+	for work := range gf.OutputChan() {
+		if work.Status() != Success {
+			t.Fatalf("expected success for %s, got: %s", work.ID, work.Status())
+		}
+		completed++
+	}
+	if completed != 3 {
+		t.Fatalf("expected 3 completed work units, got: %d", completed)
+	}
+	if strings.Join(order, ",") != "now,soon,late" {
+		t.Fatalf("expected work to be processed in order of time, got: %v", order)
+	}
+	if elapsed := processed["soon"].Sub(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("expected soon to wait 50ms, processed after: %s", elapsed)
+	}
+	if elapsed := processed["late"].Sub(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("expected late to wait 150ms, processed after: %s", elapsed)
+	}
+	if gauge := testutil.ToFloat64(gf.metrics.scheduled); gauge != 0 {
+		t.Fatalf("expected the scheduled gauge to be 0, got: %f", gauge)
+	}
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	gf.Handler().ServeHTTP(resp, req)
+	if !strings.Contains(resp.Body.String(), "gofherd_scheduled 0") {
+		t.Fatalf("expected /metrics to serve the scheduled gauge, got: %s", resp.Body.String())
+	}
+	assertAllChannelsClosed(gf, t)
+}
+
+func TestSendWorkAtSameTimeKeepsOrder(t *testing.T) {
+	var order []string
+	gf := New(func(w *Work) Status {
+		order = append(order, w.ID)
+		return Success
+	})
+	gf.SetHerdSize(1)
+	gf.SetAddr("127.0.0.1:0")
+	at := time.Now().Add(20 * time.Millisecond)
+	for _, id := range []string{"a", "b", "c"} {
+		gf.SendWorkAt(Work{ID: id}, at)
+	}
+	gf.CloseInputChan()
+	gf.Start()
+	for range gf.OutputChan() {
+	}
+	if strings.Join(order, ",") != "a,b,c" {
+		t.Fatalf("expected work due at the same time to keep its order, got: %v", order)
+	}
+}
+
+func TestSendWorkAfterDoesNotBlockOrderedOutput(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	gf.SetHerdSize(1)
+	gf.SetAddr("127.0.0.1:0")
+	gf.SetOrderedOutput(1)
+	sent := make(chan struct{})
+	go func() {
+		gf.SendWorkAfter(Work{ID: "c"}, 30*time.Millisecond)
+		gf.SendWorkAfter(Work{ID: "b"}, 20*time.Millisecond)
+		gf.SendWorkAfter(Work{ID: "a"}, 10*time.Millisecond)
+		close(sent)
+	}()
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected SendWorkAfter to return right away with a full window")
+	}
+	gf.CloseInputChan()
+	gf.Start()
+	var order []string
+	for work := range gf.OutputChan() {
+		order = append(order, work.ID)
+	}
+	if strings.Join(order, ",") != "a,b,c" {
+		t.Fatalf("expected scheduled work to be emitted in the order it is due, got: %v", order)
+	}
+}
+
+func TestCancelScheduledWork(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	gf.SetHerdSize(1)
+	gf.SetAddr("127.0.0.1:0")
+	gf.SendWorkAfter(Work{ID: "a"}, time.Hour)
+	gf.CloseInputChan()
+	gf.Start()
+	if err := gf.Cancel("a"); err != nil {
+		t.Fatalf("could not cancel: %s", err)
+	}
+
+	select {
+	case work := <-gf.OutputChan():
+		if work.Status() != Cancelled {
+			t.Fatalf("expected cancelled status, got: %s", work.Status())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected cancelled scheduled work to be emitted right away")
+	}
+	if scheduled := gf.Scheduled(); scheduled != 0 {
+		t.Fatalf("expected no scheduled work, got: %d", scheduled)
+	}
+}
+
+func TestSendWorkAtAfterCloseInputChanPanics(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	hook := &countingHook{counts: make(map[string]int)}
+	gf.AddHook(hook)
+	gf.CloseInputChan()
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected SendWorkAt after CloseInputChan to panic")
+		}
+		if _, ok := gf.WorkInfo("a"); ok || hook.counts["enqueue"] != 0 {
+			t.Fatalf("expected rejected work to not be recorded, enqueue hooks ran %d times", hook.counts["enqueue"])
+		}
+	}()
+	gf.SendWorkAt(Work{ID: "a"}, time.Now())
+}
@@ -0,0 +1,404 @@
+package gofherd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// drainFlushInterval is how often Drain flushes the sinks.
+const drainFlushInterval = time.Second
+
+// writeErrorLimit is the number of write errors returned by Drain, the rest are only counted.
+const writeErrorLimit = 100
+
+// Sink interface is accepted by Drain and writes completed Work.
+// Writes may be buffered until Flush or Close.
+type Sink interface {
+	Write(work *Work) error
+	Flush() error
+	Close() error
+}
+
+// SinkOptions configures the file backed sinks.
+type SinkOptions struct {
+	// BufferSize is the size of the write buffer, it defaults to 64KB.
+	BufferSize int
+	// MaxBytes rotates the file once it would grow beyond this size. The current file is
+	// renamed with a numbered suffix, `results.jsonl.1`, `results.jsonl.2` and so on,
+	// and a new one is started. A MaxBytes of 0 never rotates.
+	MaxBytes int64
+}
+
+// record is how a Work unit is written by the sinks.
+type record struct {
+	ID      string      `json:"id"`
+	Status  string      `json:"status"`
+	Retries int64       `json:"retries"`
+	Body    interface{} `json:"body,omitempty"`
+	Result  interface{} `json:"result,omitempty"`
+	Reason  string      `json:"reason,omitempty"`
+}
+
+func newRecord(work *Work) record {
+	return record{ID: work.ID, Status: work.Status().String(), Retries: work.Retries(), Body: work.Body, Result: work.Result(), Reason: work.FailureReason()}
+}
+
+// Drain consumes the output chan until it is closed or ctx is done, writing every Work
+// unit to all the sinks. The sinks are flushed every second and closed when Drain returns.
+// A failed write does not stop Drain, all write errors are returned joined together.
+func (gf *Gofherd) Drain(ctx context.Context, sinks ...Sink) error {
+	ticker := time.NewTicker(drainFlushInterval)
+	defer ticker.Stop()
+	errs := &errorList{}
+	for {
+		select {
+		case work, ok := <-gf.OutputChan():
+			if !ok {
+				return closeSinks(sinks, errs)
+			}
+			for _, s := range sinks {
+				if err := s.Write(&work); err != nil {
+					gf.log.warn("could not write work to sink", Field{"work_id", work.ID}, Field{"error", err})
+					errs.add(fmt.Errorf("work %s: %w", work.ID, err))
+				}
+			}
+		case <-ticker.C:
+			for _, s := range sinks {
+				errs.add(s.Flush())
+			}
+		case <-ctx.Done():
+			errs.add(ctx.Err())
+			return closeSinks(sinks, errs)
+		}
+	}
+}
+
+func closeSinks(sinks []Sink, errs *errorList) error {
+	for _, s := range sinks {
+		errs.add(s.Close())
+	}
+	return errs.err()
+}
+
+// errorList keeps the first writeErrorLimit errors and counts the rest.
+type errorList struct {
+	errs    []error
+	dropped int
+}
+
+func (el *errorList) add(err error) {
+	if err == nil {
+		return
+	}
+	if len(el.errs) >= writeErrorLimit {
+		el.dropped++
+		return
+	}
+	el.errs = append(el.errs, err)
+}
+
+func (el *errorList) err() error {
+	if el.dropped > 0 {
+		return errors.Join(append(el.errs, fmt.Errorf("%d more errors", el.dropped))...)
+	}
+	return errors.Join(el.errs...)
+}
+
+// sinkFile is a buffered writer, rotating the file at path once it reaches MaxBytes.
+// Without a path it writes to w and never rotates.
+type sinkFile struct {
+	mu       sync.Mutex
+	path     string
+	opts     SinkOptions
+	file     *os.File
+	buf      *bufio.Writer
+	written  int64
+	rotated  int
+	onCreate func() []byte
+}
+
+func newSinkFile(path string, opts SinkOptions, onCreate func() []byte) (*sinkFile, error) {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 64 * 1024
+	}
+	sf := &sinkFile{path: path, opts: opts, onCreate: onCreate, rotated: lastRotation(path)}
+	if err := sf.open(); err != nil {
+		return nil, err
+	}
+	return sf, nil
+}
+
+func newWriterSinkFile(w io.Writer, onCreate func() []byte) *sinkFile {
+	sf := &sinkFile{buf: bufio.NewWriter(w), onCreate: onCreate}
+	sf.writeHeader()
+	return sf
+}
+
+// lastRotation returns the highest numbered suffix of the rotated files of path, so that
+// a restarted process does not overwrite them.
+func lastRotation(path string) int {
+	files, _ := filepath.Glob(path + ".*")
+	last := 0
+	for _, name := range files {
+		if n, err := strconv.Atoi(strings.TrimPrefix(name, path+".")); err == nil && n > last {
+			last = n
+		}
+	}
+	return last
+}
+
+// open creates the file at path, truncating it. It must be called with the lock held.
+func (sf *sinkFile) open() error {
+	file, err := os.Create(sf.path)
+	if err != nil {
+		return err
+	}
+	sf.file = file
+	sf.buf = bufio.NewWriterSize(file, sf.opts.BufferSize)
+	sf.written = 0
+	sf.writeHeader()
+	return nil
+}
+
+func (sf *sinkFile) writeHeader() {
+	if sf.onCreate == nil {
+		return
+	}
+	header := sf.onCreate()
+	n, _ := sf.buf.Write(header)
+	sf.written += int64(n)
+}
+
+// rotate renames the current file with the next numbered suffix and opens a new one.
+// If that fails, the current file is reopened for appending. It must be called with the lock held.
+func (sf *sinkFile) rotate() error {
+	err := sf.closeFile()
+	if err == nil {
+		next := sf.rotated + 1
+		if err = os.Rename(sf.path, sf.path+"."+strconv.Itoa(next)); err == nil {
+			sf.rotated = next
+			return sf.open()
+		}
+	}
+	if reopenErr := sf.reopen(); reopenErr != nil {
+		// nothing to write to, later writes fail like on a closed sink
+		sf.buf = nil
+	}
+	return err
+}
+
+// reopen opens the file at path for appending. It must be called with the lock held.
+func (sf *sinkFile) reopen() error {
+	file, err := os.OpenFile(sf.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	sf.file = file
+	sf.buf = bufio.NewWriterSize(file, sf.opts.BufferSize)
+	sf.written = info.Size()
+	return nil
+}
+
+func (sf *sinkFile) write(p []byte) error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.buf == nil {
+		return errors.New("sink is closed")
+	}
+	var rotateErr error
+	if sf.file != nil && sf.opts.MaxBytes > 0 && sf.written > 0 && sf.written+int64(len(p)) > sf.opts.MaxBytes {
+		// a failed rotation is retried on the next write, the current file keeps growing meanwhile
+		if rotateErr = sf.rotate(); sf.buf == nil {
+			return rotateErr
+		}
+	}
+	n, err := sf.buf.Write(p)
+	sf.written += int64(n)
+	if err != nil {
+		return err
+	}
+	return rotateErr
+}
+
+func (sf *sinkFile) flush() error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.buf == nil {
+		return nil
+	}
+	return sf.buf.Flush()
+}
+
+// closeFile flushes and closes the file. It must be called with the lock held.
+func (sf *sinkFile) closeFile() error {
+	err := sf.buf.Flush()
+	if sf.file != nil {
+		if closeErr := sf.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (sf *sinkFile) close() error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.buf == nil {
+		return nil
+	}
+	err := sf.closeFile()
+	sf.buf = nil
+	return err
+}
+
+type jsonLinesSink struct {
+	out *sinkFile
+}
+
+// NewJSONLinesSink returns a Sink writing a JSON object per Work unit to the file at path,
+// with the ID, status, retries, body, result and failure reason of the Work.
+func NewJSONLinesSink(path string, opts SinkOptions) (Sink, error) {
+	out, err := newSinkFile(path, opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	return jsonLinesSink{out: out}, nil
+}
+
+// NewJSONLinesWriterSink returns a Sink like NewJSONLinesSink, writing to w without rotation.
+func NewJSONLinesWriterSink(w io.Writer) Sink {
+	return jsonLinesSink{out: newWriterSinkFile(w, nil)}
+}
+
+func (js jsonLinesSink) Write(work *Work) error {
+	line, err := json.Marshal(newRecord(work))
+	if err != nil {
+		return err
+	}
+	return js.out.write(append(line, '\n'))
+}
+
+func (js jsonLinesSink) Flush() error {
+	return js.out.flush()
+}
+
+func (js jsonLinesSink) Close() error {
+	return js.out.close()
+}
+
+var csvSinkHeader = []string{"id", "status", "retries", "body", "result", "reason"}
+
+type csvSink struct {
+	out *sinkFile
+}
+
+// NewCSVSink returns a Sink writing a CSV row per Work unit to the file at path, with
+// the columns id, status, retries, body, result and reason. The header is repeated in every
+// rotated file. Body and result are formatted with fmt, a []string body is joined with spaces.
+func NewCSVSink(path string, opts SinkOptions) (Sink, error) {
+	out, err := newSinkFile(path, opts, func() []byte { return csvRow(csvSinkHeader) })
+	if err != nil {
+		return nil, err
+	}
+	return csvSink{out: out}, nil
+}
+
+func csvRow(fields []string) []byte {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write(fields)
+	w.Flush()
+	return b.Bytes()
+}
+
+func csvField(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(v, " ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (cs csvSink) Write(work *Work) error {
+	row := []string{work.ID, work.Status().String(), strconv.FormatInt(work.Retries(), 10), csvField(work.Body), csvField(work.Result()), work.FailureReason()}
+	return cs.out.write(csvRow(row))
+}
+
+func (cs csvSink) Flush() error {
+	return cs.out.flush()
+}
+
+func (cs csvSink) Close() error {
+	return cs.out.close()
+}
+
+type statusSplitSink struct {
+	mu    sync.Mutex
+	dir   string
+	opts  SinkOptions
+	sinks map[Status]Sink
+}
+
+// NewStatusSplitSink returns a Sink writing JSON lines to a file per status in dir,
+// `success.jsonl`, `failure.jsonl` and `cancelled.jsonl`. Files are created on the first
+// Work unit with that status.
+func NewStatusSplitSink(dir string, opts SinkOptions) (Sink, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &statusSplitSink{dir: dir, opts: opts, sinks: make(map[Status]Sink)}, nil
+}
+
+func (ss *statusSplitSink) Write(work *Work) error {
+	ss.mu.Lock()
+	s, ok := ss.sinks[work.Status()]
+	if !ok {
+		var err error
+		s, err = NewJSONLinesSink(filepath.Join(ss.dir, work.Status().String()+".jsonl"), ss.opts)
+		if err != nil {
+			ss.mu.Unlock()
+			return err
+		}
+		ss.sinks[work.Status()] = s
+	}
+	ss.mu.Unlock()
+	return s.Write(work)
+}
+
+func (ss *statusSplitSink) each(f func(Sink) error) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	var errs []error
+	for _, s := range ss.sinks {
+		errs = append(errs, f(s))
+	}
+	return errors.Join(errs...)
+}
+
+func (ss *statusSplitSink) Flush() error {
+	return ss.each(Sink.Flush)
+}
+
+func (ss *statusSplitSink) Close() error {
+	return ss.each(Sink.Close)
+}
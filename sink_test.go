@@ -0,0 +1,185 @@
+package gofherd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSONLinesSinkRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	sink, err := NewJSONLinesSink(path, SinkOptions{MaxBytes: 100})
+	if err != nil {
+		t.Fatalf("could not create sink: %s", err)
+	}
+	for i := 0; i < 5; i++ {
+		work := Work{ID: strings.Repeat("x", 30), Body: i}
+		if err := sink.Write(&work); err != nil {
+			t.Fatalf("unexpected error on write: %s", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("unexpected error on close: %s", err)
+	}
+
+	files, _ := filepath.Glob(path + "*")
+	if len(files) != 5 {
+		t.Fatalf("expected a file per record with rotation, got: %v", files)
+	}
+	lines := 0
+	for _, name := range files {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("expected file %s, got: %s", name, err)
+		}
+		if len(content) > 100 {
+			t.Fatalf("expected %s to be at most 100 bytes, got: %d", name, len(content))
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+			var r record
+			if err := json.Unmarshal([]byte(line), &r); err != nil || r.Status != "success" {
+				t.Fatalf("did not get expected record in %s, got: %s", name, line)
+			}
+			lines++
+		}
+	}
+	if lines != 5 {
+		t.Fatalf("expected 5 records across rotated files, got: %d", lines)
+	}
+}
+
+func TestSinkRotationContinuesNumbering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	os.WriteFile(path+".3", []byte("old\n"), 0o644)
+	sink, err := NewJSONLinesSink(path, SinkOptions{MaxBytes: 10})
+	if err != nil {
+		t.Fatalf("could not create sink: %s", err)
+	}
+	sink.Write(&Work{ID: "a"})
+	sink.Write(&Work{ID: "b"})
+	sink.Close()
+
+	if content, _ := os.ReadFile(path + ".3"); string(content) != "old\n" {
+		t.Fatalf("expected the rotated file of a previous run to be kept, got: %q", content)
+	}
+	if content, _ := os.ReadFile(path + ".4"); !strings.Contains(string(content), `"id":"a"`) {
+		t.Fatalf("expected rotation to continue after the highest suffix, got: %q", content)
+	}
+}
+
+func TestSinkRotationFailureKeepsWriting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	sink, err := NewJSONLinesSink(path, SinkOptions{MaxBytes: 10})
+	if err != nil {
+		t.Fatalf("could not create sink: %s", err)
+	}
+	// a directory in the way makes the rename fail
+	os.MkdirAll(filepath.Join(path+".1", "busy"), 0o755)
+	sink.Write(&Work{ID: "a"})
+	if err := sink.Write(&Work{ID: "b"}); err == nil {
+		t.Fatalf("expected an error when the file cannot be rotated")
+	}
+	if err := sink.Flush(); err != nil {
+		t.Fatalf("expected the sink to keep writing to the current file, got: %s", err)
+	}
+	content, _ := os.ReadFile(path)
+	if !strings.Contains(string(content), `"id":"a"`) || !strings.Contains(string(content), `"id":"b"`) {
+		t.Fatalf("expected both records in the current file, got: %q", content)
+	}
+
+	os.RemoveAll(path + ".1")
+	if err := sink.Write(&Work{ID: "c"}); err != nil {
+		t.Fatalf("expected rotation to be retried, got: %s", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("unexpected error on close: %s", err)
+	}
+	if content, _ := os.ReadFile(path); !strings.Contains(string(content), `"id":"c"`) || strings.Contains(string(content), `"id":"a"`) {
+		t.Fatalf("expected c in a new file, got: %q", content)
+	}
+}
+
+func TestCSVSinkWritesHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.csv")
+	sink, err := NewCSVSink(path, SinkOptions{})
+	if err != nil {
+		t.Fatalf("could not create sink: %s", err)
+	}
+	work := Work{ID: "a", Body: []string{"https://a.com", "x"}}
+	work.setStatus(Failure)
+	work.incrementRetries()
+	work.SetResult("timed out, twice")
+	work.reason = "dependency b failed"
+	sink.Write(&work)
+	sink.Close()
+
+	content, _ := os.ReadFile(path)
+	expected := "id,status,retries,body,result,reason\na,failure,1,https://a.com x,\"timed out, twice\",dependency b failed\n"
+	if string(content) != expected {
+		t.Fatalf("did not get expected csv, expected: %q, got: %q", expected, content)
+	}
+}
+
+func TestJSONLinesWriterSinkFlushes(t *testing.T) {
+	var b bytes.Buffer
+	sink := NewJSONLinesWriterSink(&b)
+	sink.Write(&Work{ID: "a"})
+	if b.Len() != 0 {
+		t.Fatalf("expected write to be buffered, got: %s", b.String())
+	}
+	sink.Flush()
+	if b.String() != "{\"id\":\"a\",\"status\":\"success\",\"retries\":0}\n" {
+		t.Fatalf("did not get expected output after flush, got: %s", b.String())
+	}
+}
+
+func TestDrainSplitsByStatus(t *testing.T) {
+	dir := t.TempDir()
+	gf := New(func(w *Work) Status {
+		if w.ID == "1" {
+			return Failure
+		}
+		return Success
+	})
+	gf.SetHerdSize(2)
+	gf.SetAddr("127.0.0.1:0")
+	split, err := NewStatusSplitSink(dir, SinkOptions{})
+	if err != nil {
+		t.Fatalf("could not create sink: %s", err)
+	}
+	go func() {
+		gf.SendWork(Work{ID: "0", Body: 0})
+		gf.SendWork(Work{ID: "1", Body: 1})
+		gf.SendWork(Work{ID: "2", Body: make(chan int)})
+		gf.CloseInputChan()
+	}()
+	var b bytes.Buffer
+	if err := gf.Start(); err != nil {
+		t.Fatalf("could not start gofherd: %s", err)
+	}
+
+	err = gf.Drain(context.Background(), split, NewJSONLinesWriterSink(&b))
+	var jsonErr *json.UnsupportedTypeError
+	if !errors.As(err, &jsonErr) {
+		t.Fatalf("expected a write error for the unsupported body, got: %v", err)
+	}
+	assertAllChannelsClosed(gf, t)
+
+	for name, num := range map[string]int{"success.jsonl": 1, "failure.jsonl": 1} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("expected file %s, got: %s", name, err)
+		}
+		if lines := strings.Count(string(content), "\n"); lines != num {
+			t.Fatalf("expected %d records in %s, got: %d", num, name, lines)
+		}
+	}
+	if lines := strings.Count(b.String(), "\n"); lines != 2 {
+		t.Fatalf("expected 2 records in the writer sink, got: %d", lines)
+	}
+}
@@ -0,0 +1,264 @@
+package gofherd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// parseErrorLimit is the number of parse errors kept in ConsumeResult, the rest are only counted.
+const parseErrorLimit = 100
+
+// maxLineSize is the longest line read by the line based sources.
+const maxLineSize = 1 << 20
+
+// Source interface is accepted by Consume and produces Work until it returns io.EOF.
+// A record which cannot be parsed is reported with a *ParseError, and Consume moves on
+// to the next one. Any other error stops Consume.
+// Work with an empty ID is given the number of the record, counting from 0.
+type Source interface {
+	Next(ctx context.Context) (Work, error)
+}
+
+// ParseError is returned by a Source for a record it could not parse.
+type ParseError struct {
+	Line int64
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ConsumeResult is the number of Work units sent by Consume and the records skipped.
+// At most 100 parse errors are kept in ParseErrors, Skipped counts all of them.
+type ConsumeResult struct {
+	Sent        int64
+	Skipped     int64
+	ParseErrors []*ParseError
+}
+
+// Consume sends the Work produced by the source with SendWork until it returns io.EOF,
+// and then closes the input chan. Records which cannot be parsed are skipped and reported
+// in the result. If ctx is done or the source returns any other error, Consume returns it
+// without closing the input chan, so that the caller can decide whether to send more Work.
+// Work which no gopher picked up before ctx is done is emitted with the Cancelled status.
+func (gf *Gofherd) Consume(ctx context.Context, src Source) (ConsumeResult, error) {
+	result := ConsumeResult{}
+	records := int64(0)
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		work, err := src.Next(ctx)
+		if err == io.EOF {
+			gf.log.info("consumed source", Field{"sent", result.Sent}, Field{"skipped", result.Skipped})
+			gf.CloseInputChan()
+			return result, nil
+		}
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			records++
+			result.Skipped++
+			if len(result.ParseErrors) < parseErrorLimit {
+				result.ParseErrors = append(result.ParseErrors, parseErr)
+			}
+			gf.log.warn("skipped unparseable record", Field{"line", parseErr.Line}, Field{"error", parseErr.Err})
+			continue
+		}
+		if err != nil {
+			gf.log.error("could not read from source", Field{"error", err})
+			return result, err
+		}
+		if work.ID == "" {
+			work.ID = strconv.FormatInt(records, 10)
+		}
+		records++
+		if !gf.sendWork(context.Background(), work, ctx.Done()) {
+			return result, ctx.Err()
+		}
+		result.Sent++
+	}
+}
+
+// CSVOptions configures a CSV source.
+type CSVOptions struct {
+	// Header treats the first record as column names. The Body of each Work is then a
+	// map[string]string from column name to value, otherwise it is the []string record.
+	Header bool
+	// IDColumn is the column used as the Work ID, it requires Header.
+	IDColumn string
+	// Comma is the field delimiter, it defaults to ','.
+	Comma rune
+}
+
+type csvSource struct {
+	reader  *csv.Reader
+	opts    CSVOptions
+	columns []string
+	started bool
+}
+
+// NewCSVSource returns a Source reading CSV records from r. Records with a different
+// number of fields than the first one are reported as parse errors.
+func NewCSVSource(r io.Reader, opts CSVOptions) Source {
+	reader := csv.NewReader(r)
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	return &csvSource{reader: reader, opts: opts}
+}
+
+func (cs *csvSource) Next(ctx context.Context) (Work, error) {
+	if !cs.started {
+		cs.started = true
+		if err := cs.readHeader(); err != nil {
+			return Work{}, err
+		}
+	}
+	record, err := cs.reader.Read()
+	if err == io.EOF {
+		return Work{}, io.EOF
+	}
+	if err != nil {
+		var csvErr *csv.ParseError
+		if errors.As(err, &csvErr) {
+			return Work{}, &ParseError{Line: int64(csvErr.Line), Err: csvErr.Err}
+		}
+		return Work{}, err
+	}
+	if !cs.opts.Header {
+		return Work{Body: record}, nil
+	}
+	body := make(map[string]string, len(cs.columns))
+	for i, column := range cs.columns {
+		body[column] = record[i]
+	}
+	return Work{ID: body[cs.opts.IDColumn], Body: body}, nil
+}
+
+func (cs *csvSource) readHeader() error {
+	if !cs.opts.Header {
+		if cs.opts.IDColumn != "" {
+			return errors.New("csv source: IDColumn requires Header")
+		}
+		return nil
+	}
+	columns, err := cs.reader.Read()
+	if err != nil {
+		return fmt.Errorf("csv source: could not read header: %w", err)
+	}
+	cs.columns = columns
+	if cs.opts.IDColumn == "" {
+		return nil
+	}
+	for _, column := range columns {
+		if column == cs.opts.IDColumn {
+			return nil
+		}
+	}
+	return fmt.Errorf("csv source: ID column %q not in header", cs.opts.IDColumn)
+}
+
+type linesSource struct {
+	scanner *bufio.Scanner
+	line    int64
+	parse   func(line []byte, lineNum int64) (Work, error)
+}
+
+func newLinesSource(r io.Reader, parse func([]byte, int64) (Work, error)) *linesSource {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return &linesSource{scanner: scanner, parse: parse}
+}
+
+// Next skips blank lines.
+func (ls *linesSource) Next(ctx context.Context) (Work, error) {
+	for ls.scanner.Scan() {
+		ls.line++
+		line := ls.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		return ls.parse(line, ls.line)
+	}
+	if err := ls.scanner.Err(); err != nil {
+		return Work{}, err
+	}
+	return Work{}, io.EOF
+}
+
+// NewLinesSource returns a Source producing a Work unit per non blank line of r,
+// with the line as a string Body.
+func NewLinesSource(r io.Reader) Source {
+	return newLinesSource(r, func(line []byte, lineNum int64) (Work, error) {
+		return Work{Body: string(line)}, nil
+	})
+}
+
+// NewStdinSource returns a Source producing a Work unit per non blank line of stdin.
+func NewStdinSource() Source {
+	return NewLinesSource(os.Stdin)
+}
+
+// NewJSONLinesSource returns a Source producing a Work unit per JSON object line of r,
+// with the line as a json.RawMessage Body. If idField is set, the Work ID is taken from
+// that field, which must be a string or a number.
+func NewJSONLinesSource(r io.Reader, idField string) Source {
+	return newLinesSource(r, func(line []byte, lineNum int64) (Work, error) {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(line, &fields); err != nil {
+			return Work{}, &ParseError{Line: lineNum, Err: err}
+		}
+		work := Work{Body: json.RawMessage(append([]byte{}, line...))}
+		if idField == "" {
+			return work, nil
+		}
+		// numbers keep their literal text, large integers do not fit in a float64
+		var id interface{}
+		dec := json.NewDecoder(bytes.NewReader(fields[idField]))
+		dec.UseNumber()
+		dec.Decode(&id)
+		switch id := id.(type) {
+		case string:
+			work.ID = id
+		case json.Number:
+			work.ID = id.String()
+		default:
+			return Work{}, &ParseError{Line: lineNum, Err: fmt.Errorf("field %q is not a string or number", idField)}
+		}
+		return work, nil
+	})
+}
+
+type chanSource struct {
+	ch <-chan Work
+}
+
+// NewChanSource returns a Source producing the Work received from ch, until ch is closed.
+func NewChanSource(ch <-chan Work) Source {
+	return chanSource{ch: ch}
+}
+
+func (cs chanSource) Next(ctx context.Context) (Work, error) {
+	select {
+	case work, ok := <-cs.ch:
+		if !ok {
+			return Work{}, io.EOF
+		}
+		return work, nil
+	case <-ctx.Done():
+		return Work{}, ctx.Err()
+	}
+}
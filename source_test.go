@@ -0,0 +1,177 @@
+package gofherd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readAll(src Source, t *testing.T) ([]Work, []*ParseError) {
+	works := []Work{}
+	parseErrs := []*ParseError{}
+	for {
+		work, err := src.Next(context.Background())
+		if err == io.EOF {
+			return works, parseErrs
+		}
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			parseErrs = append(parseErrs, parseErr)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error from source: %s", err)
+		}
+		works = append(works, work)
+	}
+}
+
+func TestCSVSourceWithHeader(t *testing.T) {
+	src := NewCSVSource(strings.NewReader("id,url\na,https://a.com\nb\nc,https://c.com\n"), CSVOptions{Header: true, IDColumn: "id"})
+	works, parseErrs := readAll(src, t)
+	if len(works) != 2 || works[0].ID != "a" || works[1].ID != "c" {
+		t.Fatalf("did not get expected work, got: %+v", works)
+	}
+	if body := works[1].Body.(map[string]string); body["url"] != "https://c.com" {
+		t.Fatalf("did not get expected body, got: %+v", body)
+	}
+	if len(parseErrs) != 1 || parseErrs[0].Line != 3 {
+		t.Fatalf("expected a parse error on line 3, got: %+v", parseErrs)
+	}
+}
+
+func TestCSVSourceWithoutHeader(t *testing.T) {
+	src := NewCSVSource(strings.NewReader("a;1\nb;2\n"), CSVOptions{Comma: ';'})
+	works, _ := readAll(src, t)
+	if len(works) != 2 || !reflect.DeepEqual(works[1].Body, []string{"b", "2"}) || works[1].ID != "" {
+		t.Fatalf("did not get expected work, got: %+v", works)
+	}
+}
+
+func TestCSVSourceMissingIDColumn(t *testing.T) {
+	src := NewCSVSource(strings.NewReader("url\nhttps://a.com\n"), CSVOptions{Header: true, IDColumn: "id"})
+	if _, err := src.Next(context.Background()); err == nil {
+		t.Fatalf("expected an error for a missing ID column")
+	}
+}
+
+func TestJSONLinesSource(t *testing.T) {
+	src := NewJSONLinesSource(strings.NewReader(`{"id": "a", "n": 1}`+"\n\n"+`{"id": 2}`+"\nnot json\n"+`{"n": 3}`+"\n"), "id")
+	works, parseErrs := readAll(src, t)
+	if len(works) != 2 || works[0].ID != "a" || works[1].ID != "2" {
+		t.Fatalf("did not get expected work, got: %+v", works)
+	}
+	var body struct{ N int }
+	if err := json.Unmarshal(works[0].Body.(json.RawMessage), &body); err != nil || body.N != 1 {
+		t.Fatalf("did not get expected body, got: %+v, err: %v", body, err)
+	}
+	if len(parseErrs) != 2 || parseErrs[0].Line != 4 || parseErrs[1].Line != 5 {
+		t.Fatalf("expected parse errors on lines 4 and 5, got: %+v", parseErrs)
+	}
+}
+
+func TestJSONLinesSourceKeepsLargeNumericIDs(t *testing.T) {
+	src := NewJSONLinesSource(strings.NewReader(`{"id": 12345678901234567891}`+"\n"+`{"id": 12345678901234567892}`+"\n"+`{"id": 1.50}`+"\n"), "id")
+	works, _ := readAll(src, t)
+	if len(works) != 3 || works[0].ID != "12345678901234567891" || works[1].ID != "12345678901234567892" || works[2].ID != "1.50" {
+		t.Fatalf("expected numeric IDs to keep their literal text, got: %+v", works)
+	}
+}
+
+func TestLinesSource(t *testing.T) {
+	works, _ := readAll(NewLinesSource(strings.NewReader("one\n\ntwo")), t)
+	if len(works) != 2 || works[0].Body != "one" || works[1].Body != "two" {
+		t.Fatalf("did not get expected work, got: %+v", works)
+	}
+}
+
+func TestChanSourceIsCancelled(t *testing.T) {
+	ch := make(chan Work, 1)
+	ch <- Work{ID: "a"}
+	src := NewChanSource(ch)
+	ctx, cancel := context.WithCancel(context.Background())
+	if work, err := src.Next(ctx); err != nil || work.ID != "a" {
+		t.Fatalf("expected work a, got: %+v, err: %v", work, err)
+	}
+	cancel()
+	if _, err := src.Next(ctx); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+	close(ch)
+	if _, err := src.Next(context.Background()); err != io.EOF {
+		t.Fatalf("expected io.EOF on a closed chan, got: %v", err)
+	}
+}
+
+func TestConsume(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	gf.SetHerdSize(2)
+	gf.SetAddr("127.0.0.1:0")
+	if err := gf.Start(); err != nil {
+		t.Fatalf("could not start gofherd: %s", err)
+	}
+
+	resultCh := make(chan ConsumeResult)
+	go func() {
+		result, err := gf.Consume(context.Background(), NewJSONLinesSource(strings.NewReader("{\"id\": \"x\"}\n{}\nbad\n{}\n"), ""))
+		if err != nil {
+			t.Errorf("unexpected error from Consume: %s", err)
+		}
+		resultCh <- result
+	}()
+
+	ids := map[string]bool{}
+	for work := range gf.OutputChan() {
+		ids[work.ID] = true
+	}
+	result := <-resultCh
+	if result.Sent != 3 || result.Skipped != 1 || len(result.ParseErrors) != 1 || result.ParseErrors[0].Line != 3 {
+		t.Fatalf("did not get expected result, got: %+v", result)
+	}
+	if !reflect.DeepEqual(ids, map[string]bool{"0": true, "1": true, "3": true}) {
+		t.Fatalf("expected generated IDs from the record number, got: %v", ids)
+	}
+	assertAllChannelsClosed(gf, t)
+}
+
+func TestConsumeStopsWhenCancelled(t *testing.T) {
+	gf := New(func(w *Work) Status { return Success })
+	gf.SetHerdSize(1)
+	gf.SetAddr("127.0.0.1:0")
+	ch := make(chan Work, 2)
+	ch <- Work{ID: "a"}
+	ch <- Work{ID: "b"}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	// the herd is not started, so sending blocks
+	go func() {
+		_, err := gf.Consume(ctx, NewChanSource(ch))
+		done <- err
+	}()
+	for _, ok := gf.WorkInfo("a"); !ok; _, ok = gf.WorkInfo("a") {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected Consume to stop while sending once ctx is cancelled")
+	}
+
+	gf.CloseInputChan()
+	gf.Start()
+	for work := range gf.OutputChan() {
+		if work.Status() != Cancelled {
+			t.Fatalf("expected work which was not sent to be cancelled, got: %s", work.Status())
+		}
+	}
+	assertAllChannelsClosed(gf, t)
+}
@@ -0,0 +1,363 @@
+package gofherd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultStaleTimeout is how long a claim lives without being refreshed by its owner.
+	defaultStaleTimeout = 5 * time.Minute
+	// defaultSpoolPollInterval is how often an idle Spool looks for new or stale Work.
+	defaultSpoolPollInterval = time.Second
+)
+
+// the directories of a spool, completed Work goes to a directory per status
+const (
+	spoolPending = "pending"
+	spoolClaimed = "claimed"
+	spoolInvalid = "invalid"
+	spoolTmp     = "tmp"
+)
+
+// SpoolOptions configures a Spool.
+type SpoolOptions struct {
+	// StaleTimeout is how long a claim survives without being refreshed before any process
+	// may return it to pending. Owners refresh their claims every third of it, so it only
+	// expires when the owner died. It defaults to 5 minutes.
+	StaleTimeout time.Duration
+	// PollInterval is how often Next looks for new or stale Work while none is pending,
+	// it defaults to 1 second.
+	PollInterval time.Duration
+	// Follow keeps Next waiting for new Work instead of returning io.EOF once the spool is drained.
+	Follow bool
+}
+
+// spoolEntry is how a Work unit is stored in the pending and claimed directories.
+type spoolEntry struct {
+	ID   string          `json:"id"`
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// Spool is a Work queue stored as files in a directory, shared by the herds of several
+// processes on one host. It is a Source claiming pending Work, and a Sink moving the
+// completed Work to a directory per status, so a herd drains it with:
+//
+//	go herd.Consume(ctx, spool)
+//	herd.Start()
+//	herd.Drain(ctx, spool)
+//
+// Work is a file in `pending/` until a process claims it by renaming it to `claimed/`,
+// and the rename is atomic so that only one process gets it. Claims of a process which
+// died are returned to `pending/` after the stale timeout, and completed Work is written
+// with its status, retries and result to `success/`, `failure/` or `cancelled/`.
+// Pending Work is claimed in the order it was enqueued, and its Body is a json.RawMessage.
+type Spool struct {
+	dir     string
+	opts    SpoolOptions
+	mu      sync.Mutex
+	claims  map[string]bool
+	records int64
+	done    chan struct{}
+	stopped sync.WaitGroup
+	once    sync.Once
+}
+
+// NewSpool returns a Spool in dir, creating its directories if needed.
+func NewSpool(dir string, opts SpoolOptions) (*Spool, error) {
+	if opts.StaleTimeout <= 0 {
+		opts.StaleTimeout = defaultStaleTimeout
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultSpoolPollInterval
+	}
+	for _, sub := range []string{spoolPending, spoolClaimed, spoolTmp} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	s := &Spool{dir: dir, opts: opts, claims: make(map[string]bool), done: make(chan struct{})}
+	s.stopped.Add(1)
+	go s.refresh()
+	return s, nil
+}
+
+func (s *Spool) path(elem ...string) string {
+	return filepath.Join(append([]string{s.dir}, elem...)...)
+}
+
+// Enqueue adds the Work to the pending Work of the spool. Its ID must not be empty,
+// and its Body must be JSON encodable.
+func (s *Spool) Enqueue(w Work) error {
+	if w.ID == "" {
+		return errors.New("spool: work without an ID")
+	}
+	body, err := json.Marshal(w.Body)
+	if err != nil {
+		return fmt.Errorf("spool: could not encode body of work %s: %w", w.ID, err)
+	}
+	data, err := json.Marshal(spoolEntry{ID: w.ID, Body: body})
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%020d-%s.json", time.Now().UnixNano(), url.PathEscape(w.ID))
+	return s.writeFile(spoolPending, name, data)
+}
+
+// writeFile writes the file in the tmp directory and renames it into place,
+// so that other processes never see it half written.
+func (s *Spool) writeFile(dir, name string, data []byte) error {
+	f, err := os.CreateTemp(s.path(spoolTmp), name+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.MkdirAll(s.path(dir), 0o755); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.path(dir, name))
+}
+
+// Next claims the oldest pending Work. When none is pending, it returns stale claims to
+// pending and waits for Work, until ctx is done. Unless Follow is set, it returns io.EOF
+// once nothing is pending and the claims of other processes are gone.
+// A file which cannot be decoded is moved to `invalid/` and reported with a *ParseError.
+func (s *Spool) Next(ctx context.Context) (Work, error) {
+	for {
+		work, ok, err := s.claim()
+		if err != nil || ok {
+			return work, err
+		}
+		reclaimed, others, err := s.reclaim()
+		if err != nil {
+			return Work{}, err
+		}
+		if reclaimed > 0 {
+			continue
+		}
+		if !s.opts.Follow && others == 0 {
+			return Work{}, io.EOF
+		}
+		select {
+		case <-time.After(s.opts.PollInterval):
+		case <-ctx.Done():
+			return Work{}, ctx.Err()
+		}
+	}
+}
+
+// claim renames the oldest pending file which no other process claimed first.
+func (s *Spool) claim() (Work, bool, error) {
+	entries, err := os.ReadDir(s.path(spoolPending))
+	if err != nil {
+		return Work{}, false, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		// the claim expires relative to when it was made, not enqueued, so the file is
+		// touched before the rename makes it visible to reclaim in other processes
+		now := time.Now()
+		err := os.Chtimes(s.path(spoolPending, entry.Name()), now, now)
+		if errors.Is(err, os.ErrNotExist) {
+			// claimed by another process
+			continue
+		}
+		if err != nil {
+			return Work{}, false, err
+		}
+		id, err := newLeaseID()
+		if err != nil {
+			return Work{}, false, err
+		}
+		claimed := entry.Name() + "." + id
+		err = os.Rename(s.path(spoolPending, entry.Name()), s.path(spoolClaimed, claimed))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return Work{}, false, err
+		}
+
+		s.mu.Lock()
+		s.records++
+		line := s.records
+		s.mu.Unlock()
+		var se spoolEntry
+		data, err := os.ReadFile(s.path(spoolClaimed, claimed))
+		if err == nil {
+			err = json.Unmarshal(data, &se)
+		}
+		if err == nil && se.ID == "" {
+			err = errors.New("work without an ID")
+		}
+		if err != nil {
+			os.MkdirAll(s.path(spoolInvalid), 0o755)
+			os.Rename(s.path(spoolClaimed, claimed), s.path(spoolInvalid, entry.Name()))
+			return Work{}, false, &ParseError{Line: line, Err: fmt.Errorf("%s: %w", entry.Name(), err)}
+		}
+
+		s.mu.Lock()
+		s.claims[claimed] = true
+		s.mu.Unlock()
+		return Work{ID: se.ID, Body: se.Body, claim: claimed}, true, nil
+	}
+	return Work{}, false, nil
+}
+
+// unclaimed is the name of the pending file of a claim.
+func unclaimed(claimed string) string {
+	return claimed[:strings.LastIndex(claimed, ".")]
+}
+
+// held reports the claims of this process.
+func (s *Spool) held() map[string]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	held := make(map[string]bool, len(s.claims))
+	for claimed := range s.claims {
+		held[claimed] = true
+	}
+	return held
+}
+
+// reclaim returns the stale claims of other processes to pending, and counts the live ones.
+// Stale files in tmp are removed along the way.
+func (s *Spool) reclaim() (int, int, error) {
+	entries, err := os.ReadDir(s.path(spoolClaimed))
+	if err != nil {
+		return 0, 0, err
+	}
+	held := s.held()
+	reclaimed, others := 0, 0
+	for _, entry := range entries {
+		if held[entry.Name()] {
+			continue
+		}
+		info, err := entry.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return reclaimed, others, err
+		}
+		if time.Since(info.ModTime()) < s.opts.StaleTimeout {
+			others++
+			continue
+		}
+		err = os.Rename(s.path(spoolClaimed, entry.Name()), s.path(spoolPending, unclaimed(entry.Name())))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return reclaimed, others, err
+		}
+		reclaimed++
+	}
+	s.removeStaleTmp()
+	return reclaimed, others, nil
+}
+
+// removeStaleTmp removes the files a process which died while writing left in tmp.
+func (s *Spool) removeStaleTmp() {
+	entries, _ := os.ReadDir(s.path(spoolTmp))
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) >= s.opts.StaleTimeout {
+			os.Remove(s.path(spoolTmp, entry.Name()))
+		}
+	}
+}
+
+// refresh touches the claims of this process so that other processes don't reclaim them.
+func (s *Spool) refresh() {
+	defer s.stopped.Done()
+	ticker := time.NewTicker(s.opts.StaleTimeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		for claimed := range s.held() {
+			os.Chtimes(s.path(spoolClaimed, claimed), now, now)
+		}
+	}
+}
+
+// Write moves the claimed Work to the directory of its status, with its status,
+// retries and result. It fails if the claim was lost to another process.
+// The record is written before the claim is removed, so a process which dies in
+// between leaves a stale claim behind, and the Work is processed again.
+func (s *Spool) Write(work *Work) error {
+	s.mu.Lock()
+	ok := s.claims[work.claim]
+	delete(s.claims, work.claim)
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("spool: work %s was not claimed by this spool", work.ID)
+	}
+	claimed := s.path(spoolClaimed, work.claim)
+	if _, err := os.Stat(claimed); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("spool: claim of work %s was lost", work.ID)
+	}
+	data, err := json.Marshal(newRecord(work))
+	if err != nil {
+		os.Rename(claimed, s.path(spoolPending, unclaimed(work.claim)))
+		return fmt.Errorf("spool: could not encode work %s: %w", work.ID, err)
+	}
+	if err := s.writeFile(work.Status().String(), unclaimed(work.claim), data); err != nil {
+		os.Rename(claimed, s.path(spoolPending, unclaimed(work.claim)))
+		return err
+	}
+	err = os.Remove(claimed)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("spool: claim of work %s was lost", work.ID)
+	}
+	return err
+}
+
+// Flush is a no-op, every Write is complete when it returns.
+func (s *Spool) Flush() error {
+	return nil
+}
+
+// Close stops refreshing the claims of this process, and returns the Work still claimed
+// to pending, so that other processes can pick it up right away.
+func (s *Spool) Close() error {
+	s.once.Do(func() { close(s.done) })
+	s.stopped.Wait()
+
+	s.mu.Lock()
+	claims := s.claims
+	s.claims = make(map[string]bool)
+	s.mu.Unlock()
+	var errs []error
+	for claimed := range claims {
+		err := os.Rename(s.path(spoolClaimed, claimed), s.path(spoolPending, unclaimed(claimed)))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
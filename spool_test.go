@@ -0,0 +1,289 @@
+package gofherd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestSpool(dir string, opts SpoolOptions, t *testing.T) *Spool {
+	spool, err := NewSpool(dir, opts)
+	if err != nil {
+		t.Fatalf("could not create spool: %s", err)
+	}
+	t.Cleanup(func() { spool.Close() })
+	return spool
+}
+
+func countFiles(dir string, t *testing.T) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("could not read %s: %s", dir, err)
+	}
+	return len(entries)
+}
+
+func TestSpoolDrainedByTwoHerds(t *testing.T) {
+	dir := t.TempDir()
+	producer := newTestSpool(dir, SpoolOptions{}, t)
+	for i := 0; i < 30; i++ {
+		if err := producer.Enqueue(Work{ID: fmt.Sprintf("work/%d", i), Body: i}); err != nil {
+			t.Fatalf("could not enqueue: %s", err)
+		}
+	}
+
+	var mu sync.Mutex
+	seen := map[string]int{}
+	var wg sync.WaitGroup
+	for h := 0; h < 2; h++ {
+		spool := newTestSpool(dir, SpoolOptions{PollInterval: 10 * time.Millisecond}, t)
+		herd := New(func(w *Work) Status {
+			mu.Lock()
+			seen[w.ID]++
+			mu.Unlock()
+			var n int
+			json.Unmarshal(w.Body.(json.RawMessage), &n)
+			if n%10 == 0 {
+				return Failure
+			}
+			return Success
+		})
+		herd.SetHerdSize(3)
+		herd.DisableServer()
+		go herd.Consume(context.Background(), spool)
+		if err := herd.Start(); err != nil {
+			t.Fatalf("could not start herd: %s", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := herd.Drain(context.Background(), spool); err != nil {
+				t.Errorf("could not drain: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != 30 {
+		t.Fatalf("expected 30 work units processed, got: %d", len(seen))
+	}
+	for id, count := range seen {
+		if count != 1 {
+			t.Fatalf("expected work %s to be processed once, got: %d", id, count)
+		}
+	}
+	if n := countFiles(filepath.Join(dir, "success"), t); n != 27 {
+		t.Fatalf("expected 27 success files, got: %d", n)
+	}
+	if n := countFiles(filepath.Join(dir, "failure"), t); n != 3 {
+		t.Fatalf("expected 3 failure files, got: %d", n)
+	}
+	for _, sub := range []string{spoolPending, spoolClaimed, spoolTmp} {
+		if n := countFiles(filepath.Join(dir, sub), t); n != 0 {
+			t.Fatalf("expected %s to be empty, got %d files", sub, n)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "failure", "*"))
+	data, _ := os.ReadFile(files[0])
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil || r.Status != "failure" || r.ID != "work/0" {
+		t.Fatalf("expected the record of work/0, got: %s", data)
+	}
+}
+
+func TestSpoolReclaimsStaleClaims(t *testing.T) {
+	dir := t.TempDir()
+	dead := newTestSpool(dir, SpoolOptions{}, t)
+	dead.Enqueue(Work{ID: "a", Body: "x"})
+	if _, err := dead.Next(context.Background()); err != nil {
+		t.Fatalf("could not claim: %s", err)
+	}
+	// a process which died leaves its claim behind, without refreshing it
+	dead.mu.Lock()
+	dead.claims = map[string]bool{}
+	dead.mu.Unlock()
+
+	spool := newTestSpool(dir, SpoolOptions{StaleTimeout: 50 * time.Millisecond, PollInterval: 10 * time.Millisecond}, t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	work, err := spool.Next(ctx)
+	if err != nil || work.ID != "a" || string(work.Body.(json.RawMessage)) != `"x"` {
+		t.Fatalf("expected the stale work to be reclaimed, got: %+v, %v", work, err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("expected the claim to be reclaimed after the stale timeout, got it after %s", elapsed)
+	}
+	if _, err := spool.Next(ctx); err != io.EOF {
+		t.Fatalf("expected io.EOF once drained, got: %v", err)
+	}
+}
+
+func TestSpoolRefreshesOwnClaims(t *testing.T) {
+	dir := t.TempDir()
+	owner := newTestSpool(dir, SpoolOptions{StaleTimeout: 60 * time.Millisecond}, t)
+	owner.Enqueue(Work{ID: "a"})
+	work, _ := owner.Next(context.Background())
+
+	other := newTestSpool(dir, SpoolOptions{StaleTimeout: 60 * time.Millisecond, PollInterval: 10 * time.Millisecond}, t)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := other.Next(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected a live claim to be waited on, got: %v", err)
+	}
+
+	work.setStatus(Success)
+	if err := owner.Write(&work); err != nil {
+		t.Fatalf("could not complete work: %s", err)
+	}
+	if _, err := other.Next(context.Background()); err != io.EOF {
+		t.Fatalf("expected io.EOF once completed, got: %v", err)
+	}
+}
+
+func TestSpoolClaimIsFresh(t *testing.T) {
+	dir := t.TempDir()
+	spool := newTestSpool(dir, SpoolOptions{StaleTimeout: time.Hour}, t)
+	spool.Enqueue(Work{ID: "a"})
+	// Work enqueued long ago must not look like a stale claim once claimed
+	pending, _ := filepath.Glob(filepath.Join(dir, spoolPending, "*"))
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(pending[0], old, old)
+
+	spool.Next(context.Background())
+	claimed, _ := filepath.Glob(filepath.Join(dir, spoolClaimed, "*"))
+	info, err := os.Stat(claimed[0])
+	if err != nil || time.Since(info.ModTime()) > time.Minute {
+		t.Fatalf("expected the claim to be touched when made, got: %v, %v", info.ModTime(), err)
+	}
+}
+
+func TestSpoolDuplicateIDs(t *testing.T) {
+	dir := t.TempDir()
+	spool := newTestSpool(dir, SpoolOptions{}, t)
+	spool.Enqueue(Work{ID: "a", Body: 1})
+	spool.Enqueue(Work{ID: "a", Body: 2})
+	first, _ := spool.Next(context.Background())
+	second, _ := spool.Next(context.Background())
+
+	// completed out of order, each result goes to its own claim
+	second.setStatus(Failure)
+	first.setStatus(Success)
+	for _, work := range []Work{second, first} {
+		if err := spool.Write(&work); err != nil {
+			t.Fatalf("could not complete work: %s", err)
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "failure", "*"))
+	if len(files) != 1 || countFiles(filepath.Join(dir, "success"), t) != 1 {
+		t.Fatalf("expected a success and a failure file, got failures: %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil || r.Body != float64(2) {
+		t.Fatalf("expected the failure of the second work unit, got: %s", data)
+	}
+	if n := countFiles(filepath.Join(dir, spoolClaimed), t); n != 0 {
+		t.Fatalf("expected no claims left, got: %d", n)
+	}
+}
+
+func TestSpoolRemovesStaleTmpFiles(t *testing.T) {
+	dir := t.TempDir()
+	spool := newTestSpool(dir, SpoolOptions{StaleTimeout: time.Minute}, t)
+	// a process which died while enqueueing leaves its temp file behind
+	stale := filepath.Join(dir, spoolTmp, "0-a.json.123")
+	os.WriteFile(stale, []byte("{"), 0o644)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(stale, old, old)
+	fresh := filepath.Join(dir, spoolTmp, "0-b.json.456")
+	os.WriteFile(fresh, []byte("{"), 0o644)
+
+	if _, err := spool.Next(context.Background()); err != io.EOF {
+		t.Fatalf("expected io.EOF, got: %v", err)
+	}
+	if _, err := os.Stat(stale); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the stale temp file to be removed, got: %v", err)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Fatalf("expected a temp file being written to be kept, got: %v", err)
+	}
+}
+
+func TestSpoolWriteLostClaim(t *testing.T) {
+	dir := t.TempDir()
+	spool := newTestSpool(dir, SpoolOptions{}, t)
+	spool.Enqueue(Work{ID: "a"})
+	work, _ := spool.Next(context.Background())
+	for claimed := range spool.held() {
+		os.Rename(filepath.Join(dir, spoolClaimed, claimed), filepath.Join(dir, spoolPending, "stolen.json"))
+	}
+
+	work.setStatus(Success)
+	if err := spool.Write(&work); err == nil {
+		t.Fatalf("expected an error completing a lost claim")
+	}
+	if err := spool.Write(&Work{ID: "b"}); err == nil {
+		t.Fatalf("expected an error completing unclaimed work")
+	}
+}
+
+func TestSpoolCloseReleasesClaims(t *testing.T) {
+	dir := t.TempDir()
+	spool := newTestSpool(dir, SpoolOptions{}, t)
+	spool.Enqueue(Work{ID: "a"})
+	spool.Enqueue(Work{ID: "b"})
+	spool.Next(context.Background())
+	if err := spool.Close(); err != nil {
+		t.Fatalf("could not close: %s", err)
+	}
+	if n := countFiles(filepath.Join(dir, spoolPending), t); n != 2 {
+		t.Fatalf("expected 2 pending files, got: %d", n)
+	}
+
+	next := newTestSpool(dir, SpoolOptions{}, t)
+	work, _ := next.Next(context.Background())
+	if work.ID != "a" {
+		t.Fatalf("expected the released work to keep its place, got: %s", work.ID)
+	}
+}
+
+func TestSpoolInvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	spool := newTestSpool(dir, SpoolOptions{}, t)
+	os.WriteFile(filepath.Join(dir, spoolPending, "0-broken.json"), []byte("{"), 0o644)
+	spool.Enqueue(Work{ID: "a"})
+
+	_, err := spool.Next(context.Background())
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected a parse error, got: %v", err)
+	}
+	if n := countFiles(filepath.Join(dir, spoolInvalid), t); n != 1 {
+		t.Fatalf("expected the broken file to be moved to invalid, got %d files", n)
+	}
+	if work, err := spool.Next(context.Background()); err != nil || work.ID != "a" {
+		t.Fatalf("expected work a, got: %+v, %v", work, err)
+	}
+}
+
+func TestSpoolFollow(t *testing.T) {
+	spool := newTestSpool(t.TempDir(), SpoolOptions{Follow: true, PollInterval: 10 * time.Millisecond}, t)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		spool.Enqueue(Work{ID: "late"})
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if work, err := spool.Next(ctx); err != nil || work.ID != "late" {
+		t.Fatalf("expected to wait for new work, got: %+v, %v", work, err)
+	}
+}
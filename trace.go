@@ -0,0 +1,112 @@
+package gofherd
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Tracer interface is accepted by SetTracer and used to create spans.
+// A span is created per Work unit, from SendWork until it reaches the output chan,
+// with a child span per attempt of the processing logic.
+// It can be backed by OpenTelemetry or by a RecordingTracer in tests.
+type Tracer interface {
+	Start(ctx context.Context, name string, fields ...Field) (context.Context, Span)
+}
+
+// Span is a unit of tracing, returned by Tracer.
+type Span interface {
+	SetAttributes(fields ...Field)
+	End()
+}
+
+type noOpTracer struct {
+}
+
+func (noop noOpTracer) Start(ctx context.Context, name string, fields ...Field) (context.Context, Span) {
+	return ctx, noOpSpan{}
+}
+
+type noOpSpan struct {
+}
+
+func (noop noOpSpan) SetAttributes(fields ...Field) {
+}
+
+func (noop noOpSpan) End() {
+}
+
+// RecordedSpan is a span recorded by RecordingTracer.
+type RecordedSpan struct {
+	ID         int
+	ParentID   int
+	Name       string
+	Attributes map[string]interface{}
+	Start      time.Time
+	End        time.Time
+	Ended      bool
+}
+
+// RecordingTracer is an in-memory Tracer, useful for tests.
+type RecordingTracer struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// NewRecordingTracer initializes a new RecordingTracer.
+func NewRecordingTracer() *RecordingTracer {
+	return &RecordingTracer{}
+}
+
+type recordingSpanKey struct{}
+
+// Start creates a span, which is a child of the span found in ctx, if any.
+func (rt *RecordingTracer) Start(ctx context.Context, name string, fields ...Field) (context.Context, Span) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	span := &RecordedSpan{ID: len(rt.spans) + 1, Name: name, Attributes: make(map[string]interface{}), Start: time.Now()}
+	if parent, ok := ctx.Value(recordingSpanKey{}).(*RecordedSpan); ok {
+		span.ParentID = parent.ID
+	}
+	for _, f := range fields {
+		span.Attributes[f.Key] = f.Value
+	}
+	rt.spans = append(rt.spans, span)
+	return context.WithValue(ctx, recordingSpanKey{}, span), &recordingSpan{tracer: rt, span: span}
+}
+
+// Spans returns a copy of all the spans recorded so far.
+func (rt *RecordingTracer) Spans() []RecordedSpan {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	spans := make([]RecordedSpan, 0, len(rt.spans))
+	for _, s := range rt.spans {
+		span := *s
+		span.Attributes = make(map[string]interface{}, len(s.Attributes))
+		for k, v := range s.Attributes {
+			span.Attributes[k] = v
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+type recordingSpan struct {
+	tracer *RecordingTracer
+	span   *RecordedSpan
+}
+
+func (rs *recordingSpan) SetAttributes(fields ...Field) {
+	rs.tracer.mu.Lock()
+	defer rs.tracer.mu.Unlock()
+	for _, f := range fields {
+		rs.span.Attributes[f.Key] = f.Value
+	}
+}
+
+func (rs *recordingSpan) End() {
+	rs.tracer.mu.Lock()
+	defer rs.tracer.mu.Unlock()
+	rs.span.End = time.Now()
+	rs.span.Ended = true
+}
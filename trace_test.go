@@ -0,0 +1,73 @@
+package gofherd
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRecordingTracerParentChild(t *testing.T) {
+	rt := NewRecordingTracer()
+	ctx, parent := rt.Start(context.Background(), "parent", Field{"work_id", "abc"})
+	_, child := rt.Start(ctx, "child")
+	child.SetAttributes(Field{"status", "success"})
+	child.End()
+
+	spans := rt.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got: %d", len(spans))
+	}
+	if spans[1].ParentID != spans[0].ID || spans[0].ParentID != 0 {
+		t.Fatalf("did not get expected parent child relation, got: %+v", spans)
+	}
+	if !spans[1].Ended || spans[0].Ended {
+		t.Fatalf("did not get expected ended spans, got: %+v", spans)
+	}
+	if spans[1].Attributes["status"] != "success" || spans[0].Attributes["work_id"] != "abc" {
+		t.Fatalf("did not get expected attributes, got: %+v", spans)
+	}
+	parent.End()
+}
+
+func TestGopherdTracesWorkAndAttempts(t *testing.T) {
+	maxRetries := 2
+	rt := NewRecordingTracer()
+	var attemptSpans []int
+	gf := New(func(w *Work) Status {
+		attemptSpans = append(attemptSpans, w.Context().Value(recordingSpanKey{}).(*RecordedSpan).ID)
+		return Retry
+	})
+	gf.SetHerdSize(1)
+	gf.SetMaxRetries(int64(maxRetries))
+	gf.SetAddr("127.0.0.1:0")
+	gf.SetTracer(rt)
+
+	go func() {
+		gf.SendWork(Work{ID: "abc"})
+		gf.CloseInputChan()
+	}()
+	gf.Start()
+
+	for range gf.OutputChan() {
+	}
+
+	spans := rt.Spans()
+	if len(spans) != maxRetries+2 {
+		t.Fatalf("expected a work span and %d attempt spans, got: %+v", maxRetries+1, spans)
+	}
+	work := spans[0]
+	if work.Name != "gofherd.work" || !work.Ended || work.Attributes["status"] != "failure" || work.Attributes["retries"] != int64(maxRetries) {
+		t.Fatalf("did not get expected work span, got: %+v", work)
+	}
+	for i, attempt := range spans[1:] {
+		if attempt.Name != "gofherd.attempt" || attempt.ParentID != work.ID || !attempt.Ended {
+			t.Fatalf("did not get expected attempt span, got: %+v", attempt)
+		}
+		if attempt.Attributes["attempt"] != int64(i+1) || attempt.Attributes["status"] != "retry" {
+			t.Fatalf("did not get expected attempt attributes, got: %+v", attempt.Attributes)
+		}
+		if attemptSpans[i] != attempt.ID {
+			t.Fatalf("processing logic did not receive the attempt span in the context, expected: %d, got: %d", attempt.ID, attemptSpans[i])
+		}
+	}
+	assertAllChannelsClosed(gf, t)
+}
@@ -1,9 +1,13 @@
 package gofherd
 
-import "sync/atomic"
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
 
 // Status represents the outcome of "processing" Work.
-// It can be one of Success, Retry, Failure.
+// It can be one of Success, Retry, Failure, Cancelled.
 type Status int
 
 const (
@@ -13,12 +17,15 @@ const (
 	Retry
 	// Failure represents a failed processing outcome and should not be retried again.
 	Failure
+	// Cancelled represents Work which was cancelled with Cancel. It won't be retried.
+	Cancelled
 )
 
 var statusStrings = map[Status]string{
-	Success: "success",
-	Retry:   "retry",
-	Failure: "failure",
+	Success:   "success",
+	Retry:     "retry",
+	Failure:   "failure",
+	Cancelled: "cancelled",
 }
 
 func (r Status) String() string {
@@ -34,9 +41,23 @@ func (r Status) String() string {
 type Work struct {
 	ID     string
 	retry  int64
+	seq    uint64
 	status Status
 	Body   interface{}
 	result interface{}
+	ctx    context.Context
+	span   Span
+	// attemptCtx is set while the processing logic is running
+	attemptCtx context.Context
+	// retryAfter overrides the retry backoff for the next retry
+	retryAfter time.Duration
+	// reason is why Work failed without being processed
+	reason string
+	// claim is the file claimed for the Work by the Spool it came from
+	claim string
+	// DependsOn is the IDs of the Work units which must succeed before this one is
+	// processed, see EnableDependencies.
+	DependsOn []string
 }
 
 func (w *Work) retryCount() int64 {
@@ -47,6 +68,17 @@ func (w *Work) incrementRetries() {
 	atomic.AddInt64(&(w.retry), 1)
 }
 
+// Retries is the number of times the Work unit has been retried.
+func (w *Work) Retries() int64 {
+	return atomic.LoadInt64(&(w.retry))
+}
+
+// SetRetryAfter delays the next retry of the Work unit by d instead of the retry backoff,
+// for example to honor a `Retry-After` header. It only applies when Retry is returned.
+func (w *Work) SetRetryAfter(d time.Duration) {
+	w.retryAfter = d
+}
+
 // SetResult is used to set the result for the Work unit.
 func (w *Work) SetResult(result interface{}) {
 	w.result = result
@@ -65,3 +97,21 @@ func (w *Work) Status() Status {
 func (w *Work) Result() interface{} {
 	return w.result
 }
+
+// FailureReason is why the Work unit failed without being processed,
+// like `dependency build-a failed`. It is empty for Work which was processed.
+func (w *Work) FailureReason() string {
+	return w.reason
+}
+
+// Context returns the trace context of the Work unit. Inside the processing logic
+// it carries the span of the current attempt, so it can be used to create child spans.
+func (w *Work) Context() context.Context {
+	if w.attemptCtx != nil {
+		return w.attemptCtx
+	}
+	if w.ctx != nil {
+		return w.ctx
+	}
+	return context.Background()
+}
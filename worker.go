@@ -0,0 +1,196 @@
+package gofherd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// workerLeaseWait is how long a Worker asks `POST /lease` to wait for Work.
+	workerLeaseWait = 10 * time.Second
+	// workerErrorBackoff is how long a Worker waits after failing to reach the coordinator.
+	workerErrorBackoff = time.Second
+)
+
+// errLeaseLost is returned by heartbeat when the coordinator no longer knows the lease.
+var errLeaseLost = errors.New("lease lost")
+
+// Worker processes the Work of a coordinator started with NewCoordinator, in a remote process.
+// The processing logic receives Work with the ID of the Work on the coordinator and the
+// JSON encoded Body as a json.RawMessage. Its Result is JSON encoded and sent back.
+type Worker struct {
+	// URL is the address of the coordinator, like `http://10.0.0.1:2112`.
+	URL string
+	// AuthToken is the bearer token set on the coordinator with SetAuthToken, if any.
+	AuthToken string
+	// Client sends the requests, it defaults to http.DefaultClient.
+	Client *http.Client
+	// Process is the processing logic. Its context is cancelled if the lease is lost.
+	Process func(context.Context, *Work) Status
+	// HeartbeatInterval is how often the lease is extended, it should be well below the lease
+	// timeout of the coordinator. It defaults to 10 seconds.
+	HeartbeatInterval time.Duration
+	// OnError is called with the errors talking to the coordinator, which are otherwise retried.
+	OnError func(error)
+}
+
+// Run leases and processes Work one unit at a time until ctx is done. Run several
+// Workers, or Run in several goroutines, to process Work in parallel.
+func (wk *Worker) Run(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		lease, err := wk.lease(ctx)
+		if err != nil {
+			wk.fail(ctx, err)
+			continue
+		}
+		if lease == nil {
+			continue
+		}
+		if err := wk.process(ctx, lease); err != nil {
+			wk.fail(ctx, err)
+		}
+	}
+}
+
+// fail reports the error and waits before talking to the coordinator again.
+func (wk *Worker) fail(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	if wk.OnError != nil {
+		wk.OnError(err)
+	}
+	select {
+	case <-time.After(workerErrorBackoff):
+	case <-ctx.Done():
+	}
+}
+
+func (wk *Worker) process(ctx context.Context, lease *Lease) error {
+	leaseCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		wk.heartbeat(leaseCtx, lease.LeaseID, cancel)
+	}()
+
+	work := Work{ID: lease.WorkID, Body: lease.Body, retry: lease.Attempt - 1}
+	status := wk.Process(leaseCtx, &work)
+	lost := leaseCtx.Err() != nil && ctx.Err() == nil
+	cancel()
+	<-heartbeatDone
+	if lost || ctx.Err() != nil {
+		// the coordinator retries it once the lease expires
+		return nil
+	}
+
+	completion := Completion{LeaseID: lease.LeaseID, Status: status.String()}
+	if status != Success && status != Failure {
+		completion.Status = Retry.String()
+	}
+	if result := work.Result(); result != nil {
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("could not encode result of work %s: %w", work.ID, err)
+		}
+		completion.Result = encoded
+	}
+	resp, err := wk.post(ctx, "/complete", completion)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusConflict {
+		return fmt.Errorf("completed work %s after its lease was lost", work.ID)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not complete work %s: %s", work.ID, resp.Status)
+	}
+	return nil
+}
+
+// heartbeat extends the lease until ctx is done, calling lost if the lease is lost.
+func (wk *Worker) heartbeat(ctx context.Context, leaseID string, lost func()) {
+	interval := wk.HeartbeatInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		resp, err := wk.post(ctx, "/heartbeat", leaseRequest{LeaseID: leaseID})
+		if err != nil {
+			if ctx.Err() == nil && wk.OnError != nil {
+				wk.OnError(err)
+			}
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusConflict {
+			if wk.OnError != nil {
+				wk.OnError(errLeaseLost)
+			}
+			lost()
+			return
+		}
+	}
+}
+
+// lease asks the coordinator for Work, returning nil if none is available.
+func (wk *Worker) lease(ctx context.Context) (*Lease, error) {
+	resp, err := wk.post(ctx, "/lease?wait="+workerLeaseWait.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return nil, nil
+	case http.StatusOK:
+		var lease Lease
+		if err := json.NewDecoder(resp.Body).Decode(&lease); err != nil {
+			return nil, err
+		}
+		return &lease, nil
+	default:
+		return nil, fmt.Errorf("could not lease work: %s", resp.Status)
+	}
+}
+
+func (wk *Worker) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(wk.URL, "/")+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if wk.AuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+wk.AuthToken)
+	}
+	client := wk.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}